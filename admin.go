@@ -0,0 +1,187 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/obnahsgnaw/api/internal/midswitch"
+	"github.com/obnahsgnaw/api/service"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// middleware kinds
+const (
+	MidHttp = "http"
+	MidMux  = "mux"
+)
+
+// MiddlewareState the runtime state of a middleware
+type MiddlewareState struct {
+	Name    string   `json:"name"`
+	Kind    string   `json:"kind"`
+	Enabled bool     `json:"enabled"`
+	Ignores []string `json:"ignores"`
+}
+
+// MiddlewareUpdate the admin update of a middleware, nil fields keep unchanged
+type MiddlewareUpdate struct {
+	Enabled *bool     `json:"enabled"`
+	Ignores *[]string `json:"ignores"`
+}
+
+func (s *Server) midSwitch(kind, name string) *midswitch.Switch {
+	switches := s.midSwitches
+	if kind == MidMux {
+		switches = s.muxMidSwitches
+	}
+	if _, ok := switches[name]; !ok {
+		switches[name] = midswitch.New()
+	}
+	return switches[name]
+}
+
+func (s *Server) matchedSwitches(name string) map[string]*midswitch.Switch {
+	matched := make(map[string]*midswitch.Switch)
+	if _, ok := s.middlewarePds[name]; ok {
+		matched[MidHttp] = s.midSwitch(MidHttp, name)
+	}
+	if _, ok := s.muxMiddlewarePds[name]; ok {
+		matched[MidMux] = s.midSwitch(MidMux, name)
+	}
+	return matched
+}
+
+// SetMiddlewareEnabled enable or disable the named middleware at runtime
+func (s *Server) SetMiddlewareEnabled(name string, enabled bool) error {
+	switches := s.matchedSwitches(name)
+	if len(switches) == 0 {
+		return errors.New("middleware[" + name + "] not found")
+	}
+	for _, sw := range switches {
+		sw.SetEnabled(enabled)
+	}
+	s.logger.Info("middleware[" + name + "] enabled=" + strconv.FormatBool(enabled))
+	return nil
+}
+
+// SetMiddlewareIgnores set the ignore rules of the named middleware at runtime, rule is a path prefix or "METHOD path-prefix", mux middlewares
+// match the pattern too. The gateway middlewares see the path without the /{id} after the version, the rules of the full path
+// /{version}/{id}/xxx are stored as /{version}/xxx
+func (s *Server) SetMiddlewareIgnores(name string, ignores []string) error {
+	switches := s.matchedSwitches(name)
+	if len(switches) == 0 {
+		return errors.New("middleware[" + name + "] not found")
+	}
+	rules := make([]string, 0, len(ignores))
+	for _, rule := range ignores {
+		normalized, err := s.ignoreRule(rule)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, normalized)
+	}
+	for _, sw := range switches {
+		sw.SetIgnores(rules)
+	}
+	s.logger.Info("middleware[" + name + "] ignores updated")
+	return nil
+}
+
+// ignoreRule validate the rule and strip the /{id} after the version of its path
+func (s *Server) ignoreRule(rule string) (string, error) {
+	method, prefix := "", strings.TrimSpace(rule)
+	if i := strings.Index(prefix, " "); i > 0 {
+		method, prefix = strings.ToUpper(prefix[:i])+" ", strings.TrimSpace(prefix[i+1:])
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		return "", errors.New("ignore rule[" + rule + "] invalid, a path prefix or \"METHOD path-prefix\" expected")
+	}
+	if !s.withoutRoutePrefix {
+		for _, v := range s.Versions() {
+			full := "/" + v.String() + "/" + s.id
+			if prefix == full || strings.HasPrefix(prefix, full+"/") {
+				prefix = "/" + v.String() + strings.TrimPrefix(prefix, full)
+				break
+			}
+		}
+	}
+	return method + prefix, nil
+}
+
+// MiddlewareStates return the runtime state of the middlewares
+func (s *Server) MiddlewareStates() []MiddlewareState {
+	var states []MiddlewareState
+	for name := range s.middlewarePds {
+		sw := s.midSwitch(MidHttp, name)
+		states = append(states, MiddlewareState{Name: name, Kind: MidHttp, Enabled: sw.Enabled(), Ignores: sw.Ignores()})
+	}
+	for name := range s.muxMiddlewarePds {
+		sw := s.midSwitch(MidMux, name)
+		states = append(states, MiddlewareState{Name: name, Kind: MidMux, Enabled: sw.Enabled(), Ignores: sw.Ignores()})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Kind != states[j].Kind {
+			return states[i].Kind < states[j].Kind
+		}
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
+
+// adminRoute the middleware admin api, accessible in debug mode or with the admin token in the X-Admin-Token header
+func (s *Server) adminRoute(prefix, token string) service.RouteProvider {
+	return func(engine *gin.Engine) {
+		g := engine.Group(prefix, func(c *gin.Context) {
+			if s.app.Debugger().Debug() {
+				return
+			}
+			if token != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Admin-Token")), []byte(token)) == 1 {
+				return
+			}
+			if token == "" {
+				c.AbortWithStatus(http.StatusNotFound)
+			} else {
+				c.AbortWithStatus(http.StatusForbidden)
+			}
+		})
+		g.GET("/middlewares", func(c *gin.Context) {
+			c.JSON(http.StatusOK, s.MiddlewareStates())
+		})
+		if s.breaker != nil {
+			g.GET("/breakers", func(c *gin.Context) {
+				c.JSON(http.StatusOK, s.breaker.States())
+			})
+		}
+		g.PUT("/middlewares/:name", func(c *gin.Context) {
+			var update MiddlewareUpdate
+			if err := c.ShouldBindJSON(&update); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			name := c.Param("name")
+			if len(s.matchedSwitches(name)) == 0 {
+				c.JSON(http.StatusNotFound, gin.H{"message": "middleware[" + name + "] not found"})
+				return
+			}
+			if update.Ignores != nil {
+				if err := s.SetMiddlewareIgnores(name, *update.Ignores); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+					return
+				}
+			}
+			if update.Enabled != nil {
+				_ = s.SetMiddlewareEnabled(name, *update.Enabled)
+			}
+			var states []MiddlewareState
+			for _, st := range s.MiddlewareStates() {
+				if st.Name == name {
+					states = append(states, st)
+				}
+			}
+			c.JSON(http.StatusOK, states)
+		})
+	}
+}
@@ -7,11 +7,20 @@ import (
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/obnahsgnaw/api/engine"
 	"github.com/obnahsgnaw/api/internal/errhandler"
+	"github.com/obnahsgnaw/api/internal/inflight"
+	"github.com/obnahsgnaw/api/internal/middleware/deadlinemid"
+	"github.com/obnahsgnaw/api/internal/midswitch"
 	"github.com/obnahsgnaw/api/internal/server"
 	"github.com/obnahsgnaw/api/pkg/apierr"
 	"github.com/obnahsgnaw/api/pkg/errobj"
+	"github.com/obnahsgnaw/api/pkg/pipeline"
 	"github.com/obnahsgnaw/api/service"
 	"github.com/obnahsgnaw/api/service/apidoc"
+	"github.com/obnahsgnaw/api/service/breaker"
+	"github.com/obnahsgnaw/api/service/compress"
+	"github.com/obnahsgnaw/api/service/cors"
+	"github.com/obnahsgnaw/api/service/metrics"
+	"github.com/obnahsgnaw/api/service/tracing"
 	"github.com/obnahsgnaw/application"
 	"github.com/obnahsgnaw/application/endtype"
 	"github.com/obnahsgnaw/application/pkg/logging/logger"
@@ -25,6 +34,8 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Version int
@@ -45,25 +56,49 @@ type Server struct {
 	rpcServer          *rpc.Server
 	logger             *zap.Logger
 	logCnf             *logger.Config
-	services           []ServiceProvider
 	regInfo            *regCenter.RegInfo
 	docRegInfos        []*regCenter.RegInfo
 	mdProvider         *service.MethodMdProvider
 	errObjProvider     errobj.Provider
 	version            Version
+	versions           map[Version]*apiVersion
 	regEnable          bool
 	middlewarePds      map[string]func() gin.HandlerFunc
+	middlewareOrder    *pipeline.Pipeline
 	muxMiddlewarePds   map[string]func() service.MuxRouteHandleFunc
+	muxMiddlewareOrder *pipeline.Pipeline
+	midSwitches        map[string]*midswitch.Switch
+	muxMidSwitches     map[string]*midswitch.Switch
 	extRoutePds        []func() service.RouteProvider
 	gatewayKeyGen      func() (string, error)
 	gatewayKey         string
-	running            bool
+	lifecycle          *lifecycle
+	breaker            *breaker.Manager
+	deadline           *deadlinemid.Config
+	cors               *cors.Config
+	compress           *compress.Config
+	metrics            *metrics.Manager
+	tracing            *tracing.Manager
+	signHeaderKey      string
 	muxRoutes          []func(*runtime.ServeMux) error
 	staticRoutes       server.StaticRoute
 	withoutRoutePrefix bool
-}
-
-// ServiceProvider api service provider
+	rpcMethods         *server.RpcMethods
+	muxRouteInfos      []routeInfo
+	extRouteInfos      []routeInfo
+	docPaths           map[string]struct{}
+	inflight           *inflight.Counter
+	drainTimeout       time.Duration
+	readiness          *readiness
+	watcher            *watcher
+	regLock            sync.Mutex
+	regRetry           RegRetryPolicy
+	regStatus          regStatus
+	regCancel          context.CancelFunc
+	optionChecks       []func() error // errors of the option configs, checked when the server initialized
+}
+
+// ServiceProvider api service provider, return the full name of the service, such as pkg.UserService, the routes of it are listed from the http rules of the descriptor
 type ServiceProvider func(ctx context.Context, mux *runtime.ServeMux) (name string, err error)
 
 func New(app *application.Application, e *engine.MuxHttp, id, name string, et endtype.EndType, v Version, options ...Option) *Server {
@@ -75,28 +110,46 @@ func New(app *application.Application, e *engine.MuxHttp, id, name string, et en
 		serverType: servertype.Api,
 		httpEngine: e,
 		version:    v,
+		versions:   map[Version]*apiVersion{v: {version: v, mux: e.Mux()}},
 		errFactory: apierr.New(1),
 		errObjProvider: func(param errobj.Param) interface{} {
 			return param
 		},
-		mdProvider:       service.NewMdProvider(),
-		middlewarePds:    make(map[string]func() gin.HandlerFunc),
-		muxMiddlewarePds: make(map[string]func() service.MuxRouteHandleFunc),
-		logCnf:           app.LogConfig(),
-		logger:           app.Logger().Named(utils.ToStr(id, "-", et.String(), "-", servertype.Api.String())),
-		staticRoutes:     server.NewStaticRoute(),
+		mdProvider:         service.NewMdProvider(),
+		middlewarePds:      make(map[string]func() gin.HandlerFunc),
+		middlewareOrder:    pipeline.New(),
+		muxMiddlewarePds:   make(map[string]func() service.MuxRouteHandleFunc),
+		muxMiddlewareOrder: pipeline.New(),
+		midSwitches:        make(map[string]*midswitch.Switch),
+		muxMidSwitches:     make(map[string]*midswitch.Switch),
+		logCnf:             app.LogConfig(),
+		logger:             app.Logger().Named(utils.ToStr(id, "-", et.String(), "-", servertype.Api.String())),
+		staticRoutes:       server.NewStaticRoute(),
+		rpcMethods:         server.NewRpcMethods(),
+		docPaths:           make(map[string]struct{}),
+		inflight:           inflight.New(),
+		drainTimeout:       10 * time.Second,
+		readiness:          newReadiness("services", "registry", "serving"),
+		watcher:            newWatcher(),
+		regRetry:           DefaultRegRetryPolicy(),
+		lifecycle:          newLifecycle(),
 	}
 	e.Http().AddInitializer(s.initHttp)
 	s.With(options...)
 	return s
 }
 
-func (s *Server) With(options ...Option) {
+// With apply the options, only allowed before the server initialized
+func (s *Server) With(options ...Option) error {
+	if err := s.configurable("with"); err != nil {
+		return err
+	}
 	for _, o := range options {
 		if o != nil {
 			o(s)
 		}
 	}
+	return nil
 }
 
 // ID return the api service id
@@ -159,72 +212,133 @@ func (s *Server) Rpc() *rpc.Server {
 	return s.rpcServer
 }
 
-// RegisterApiService register a api service
-func (s *Server) RegisterApiService(provider ServiceProvider) {
-	s.services = append(s.services, provider)
+// RegisterApiService register a api service to the default version
+func (s *Server) RegisterApiService(provider ServiceProvider) error {
+	return s.RegisterVersionApiService(s.version, provider)
 }
 
 // RegisterRpcService register a rcp service
-func (s *Server) RegisterRpcService(provider rpc.ServiceInfo) {
+func (s *Server) RegisterRpcService(provider rpc.ServiceInfo) error {
+	if err := s.configurable("register rpc service"); err != nil {
+		return err
+	}
 	if s.rpcServer != nil {
 		s.rpcServer.RegisterService(provider)
 	}
+	return nil
 }
 
-func (s *Server) AddMiddleware(name string, mid func() gin.HandlerFunc, force bool) {
+// AddMiddleware add a gin middleware, the run order is resolved by the rules, see pipeline.Priority, pipeline.Before and pipeline.After
+func (s *Server) AddMiddleware(name string, mid func() gin.HandlerFunc, force bool, rules ...pipeline.Rule) error {
+	if err := s.configurable("add middleware"); err != nil {
+		return err
+	}
 	if _, ok := s.middlewarePds[name]; ok && force || !ok {
 		if s.logger != nil {
 			s.logger.Debug(name + "middleware enabled")
 		}
 		s.middlewarePds[name] = mid
+		s.middlewareOrder.Add(name, rules...)
+		s.midSwitch(MidHttp, name)
 	}
+	return nil
 }
 
-func (s *Server) AddMuxMiddleware(name string, mid func() service.MuxRouteHandleFunc, force bool) {
+// AddMuxMiddleware add a mux middleware, the run order is resolved by the rules, see pipeline.Priority, pipeline.Before and pipeline.After
+func (s *Server) AddMuxMiddleware(name string, mid func() service.MuxRouteHandleFunc, force bool, rules ...pipeline.Rule) error {
+	if err := s.configurable("add mux middleware"); err != nil {
+		return err
+	}
 	if _, ok := s.muxMiddlewarePds[name]; ok && force || !ok {
 		if s.logger != nil {
 			s.logger.Debug(name + "middleware enabled")
 		}
 		s.muxMiddlewarePds[name] = mid
+		s.muxMiddlewareOrder.Add(name, rules...)
+		s.midSwitch(MidMux, name)
 	}
+	return nil
+}
+
+// Middlewares return the gin middleware names in run order
+func (s *Server) Middlewares() ([]string, error) {
+	return s.middlewareOrder.Resolve()
 }
 
-func (s *Server) AddRoute(route func() service.RouteProvider) {
+// MuxMiddlewares return the mux middleware names in run order
+func (s *Server) MuxMiddlewares() ([]string, error) {
+	return s.muxMiddlewareOrder.Resolve()
+}
+
+func (s *Server) AddRoute(route func() service.RouteProvider) error {
+	if err := s.configurable("add route"); err != nil {
+		return err
+	}
 	s.extRoutePds = append(s.extRoutePds, route)
+	return nil
 }
 
 // AddMuxRoute MuxRoute need add id prefix to access
-func (s *Server) AddMuxRoute(meth string, pathPattern string, h func(w http.ResponseWriter, r *http.Request, pathParams map[string]string)) {
+func (s *Server) AddMuxRoute(meth string, pathPattern string, h func(w http.ResponseWriter, r *http.Request, pathParams map[string]string)) error {
+	if err := s.configurable("add mux route"); err != nil {
+		return err
+	}
+	s.addMuxRoute(RouteMux, meth, pathPattern, h)
+	return nil
+}
+
+func (s *Server) addMuxRoute(kind, meth string, pathPattern string, h func(w http.ResponseWriter, r *http.Request, pathParams map[string]string)) {
+	s.muxRouteInfos = append(s.muxRouteInfos, routeInfo{kind: kind, method: strings.ToUpper(meth), pattern: pathPattern})
 	s.muxRoutes = append(s.muxRoutes, func(mux *runtime.ServeMux) error {
-		return mux.HandlePath(strings.ToUpper(meth), pathPattern, h)
+		return s.rpcMethods.HandlePath(mux, strings.ToUpper(meth), pathPattern, h)
 	})
 }
 
 // AddMuxStaticRoute 文件路由，pathPattern 需要为 /xx/{path}
-func (s *Server) AddMuxStaticRoute(meth string, pathPattern string, h func(w http.ResponseWriter, r *http.Request, pathParams map[string]string)) {
+func (s *Server) AddMuxStaticRoute(meth string, pathPattern string, h func(w http.ResponseWriter, r *http.Request, pathParams map[string]string)) error {
+	if err := s.configurable("add mux static route"); err != nil {
+		return err
+	}
 	s.staticRoutes.Add(meth, pathPattern)
-	s.AddMuxRoute(meth, pathPattern+"/{path}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+	s.addMuxRoute(RouteStatic, meth, pathPattern+"/{path}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
 		if _, ok := pathParams["path"]; ok {
 			pathParams["path"] = s.staticRoutes.Decode(pathParams["path"])
 		}
 		h(w, r, pathParams)
 	})
+	return nil
 }
 
-func (s *Server) AddDefIncomeMd(key string, valProvider service.MdValParser) {
+func (s *Server) AddDefIncomeMd(key string, valProvider service.MdValParser) error {
+	if err := s.configurable("add income md"); err != nil {
+		return err
+	}
 	s.mdProvider.AddDefault(key, valProvider)
+	return nil
 }
 
-func (s *Server) AddAllIncomeMd() {
+func (s *Server) AddAllIncomeMd() error {
+	if err := s.configurable("add income md"); err != nil {
+		return err
+	}
 	s.mdProvider.AddAll()
+	return nil
 }
 
-func (s *Server) AddIncomeMd(method, key string, valProvider service.MdValParser) {
+func (s *Server) AddIncomeMd(method, key string, valProvider service.MdValParser) error {
+	if err := s.configurable("add income md"); err != nil {
+		return err
+	}
 	s.mdProvider.Add(method, key, valProvider)
+	return nil
 }
 
-func (s *Server) AddMethodAllIncomeMd(method string) {
+func (s *Server) AddMethodAllIncomeMd(method string) error {
+	if err := s.configurable("add income md"); err != nil {
+		return err
+	}
 	s.mdProvider.AddMethodAll(method)
+	return nil
 }
 
 // ErrCode return err code factory
@@ -233,11 +347,20 @@ func (s *Server) ErrCode() *apierr.Factory {
 }
 
 func (s *Server) Run(failedCb func(error)) {
-	if s.running {
+	var err error
+	if _, err = s.transit("run", StateInitializing, StateCreated); err != nil {
+		failedCb(err)
 		return
 	}
-	var err error
+	failed := func(err error) {
+		s.initFailed()
+		failedCb(err)
+	}
 	s.logger.Info("init start...")
+	if _, err = s.Middlewares(); err != nil {
+		failed(s.apiServerError(s.msg("middleware order resolve failed"), err))
+		return
+	}
 	s.initRegInfo()
 	if s.rpcServer != nil {
 		s.app.AddServer(s.rpcServer)
@@ -251,65 +374,166 @@ func (s *Server) Run(failedCb func(error)) {
 			}
 		}
 	}
-	if err = s.initEngine(); err != nil {
-		failedCb(err)
+	if err = s.initServices(); err != nil {
+		failed(err)
 		return
 	}
-	for _, sp := range s.services {
-		if n, err1 := sp(s.app.Context(), s.httpEngine.Mux()); err1 != nil {
-			failedCb(s.apiServerError(s.msg("service[", n, "] register failed"), err1))
-			return
-		} else {
-			s.logger.Debug("service[" + n + "] registered")
-		}
-	}
-	s.logger.Info("services initialized")
 	if s.app.Register() != nil {
-		if err = s.register(true); err != nil {
-			failedCb(s.apiServerError(s.msg("register failed"), err))
-			return
-		}
-		s.logger.Debug("server registered")
-		if err = s.regGateway(); err != nil {
-			failedCb(err)
-			return
+		ctx := s.regContext()
+		if s.regRetry.Background {
+			go func() {
+				if err1 := s.registerWithRetry(ctx); err1 != nil {
+					if ctx.Err() == nil {
+						s.logger.Error("background register failed, err=" + err1.Error())
+						failedCb(err1)
+					}
+					return
+				}
+				s.watch(ctx)
+			}()
+			s.logger.Debug("register retrying in background")
+		} else {
+			if err = s.registerWithRetry(ctx); err != nil {
+				failed(err)
+				return
+			}
+			s.watch(ctx)
 		}
-		s.logger.Debug("gateway registered")
+	} else {
+		s.regStatus.set(RegDisabled, 0, nil)
+		s.readiness.set("registry", true)
+		s.readiness.remove("gateway")
 	}
 	s.logger.Info("register initialized")
 	s.logger.Info("initialized")
+	if _, err = s.transit("run", StateRunning, StateInitializing); err != nil {
+		// released while initializing
+		return
+	}
 	go func() {
 		defer s.httpEngine.Http().CloseWithKey(s.id)
 		s.httpEngine.Http().RunAndServWithKey(s.id, func(err error) {
+			if s.State() != StateRunning {
+				return
+			}
 			failedCb(s.apiServerError(s.msg("engine run failed, err="+err.Error()), nil))
 		})
 	}()
 	s.logger.Info(utils.ToStr("server[", s.Host().String(), "] listen and serving..."))
-	s.running = true
+	s.readiness.set("serving", true)
+}
+
+// initFailed stop the registration and move to stopped after the init failed
+func (s *Server) initFailed() {
+	s.cancelReg()
+	s.stopWatch()
+	_, _ = s.transit("init", StateStopped, StateInitializing)
+}
+
+// Handler init the server without listening and registering, and return the http handler, for in-process serving such as tests
+func (s *Server) Handler() (http.Handler, error) {
+	if _, err := s.transit("handler", StateInitializing, StateCreated); err != nil {
+		return nil, err
+	}
+	s.logger.Info("init start...")
+	if err := s.initServices(); err != nil {
+		s.initFailed()
+		return nil, err
+	}
+	if err := s.initHttp(); err != nil {
+		s.initFailed()
+		return nil, err
+	}
+	s.regStatus.set(RegDisabled, 0, nil)
+	s.readiness.set("registry", true)
+	s.readiness.remove("gateway")
+	s.readiness.set("serving", true)
+	s.logger.Info("initialized")
+	if _, err := s.transit("handler", StateRunning, StateInitializing); err != nil {
+		return nil, err
+	}
+	return s.httpEngine.Http().Engine(), nil
+}
+
+func (s *Server) initServices() error {
+	for _, check := range s.optionChecks {
+		if err := check(); err != nil {
+			return s.apiServerError(s.msg("option invalid"), err)
+		}
+	}
+	if err := s.initEngine(); err != nil {
+		return err
+	}
+	for _, v := range s.Versions() {
+		for _, sp := range s.versions[v].services {
+			if n, err := sp(s.app.Context(), s.versions[v].mux); err != nil {
+				return s.apiServerError(s.msg("service[", v.String(), ".", n, "] register failed"), err)
+			} else {
+				if !s.rpcMethods.AddService(s.versions[v].mux, n) {
+					s.logger.Debug("service[" + v.String() + "." + n + "] not found in the proto registry, the routes of it are not listed")
+				}
+				s.logger.Debug("service[" + v.String() + "." + n + "] registered")
+			}
+		}
+	}
+	s.logger.Info("services initialized")
+	s.readiness.set("services", true)
+	return nil
 }
 
 func (s *Server) initHttp() error {
-	var mid []gin.HandlerFunc
-	for _, m := range s.middlewarePds {
-		mid = append(mid, m())
+	names, err := s.Middlewares()
+	if err != nil {
+		return s.apiServerError(s.msg("middleware order resolve failed"), err)
+	}
+	s.logger.Info("middleware order: " + strings.Join(names, ","))
+	var versions []string
+	var negotiation *gin.Engine
+	if len(s.versions) > 1 && !s.withoutRoutePrefix {
+		negotiation = server.NewNegotiationEngine(s.httpEngine.Http().Engine())
+	}
+	for _, v := range s.Versions() {
+		av := s.versions[v]
+		mid := []gin.HandlerFunc{s.drainMid(), server.NewVersionMid(v.String(), av.deprecation, av.sunset)}
+		for _, n := range names {
+			mid = append(mid, midswitch.Gin(s.midSwitch(MidHttp, n), s.middlewarePds[n]()))
+		}
+		server.InitRpcHttpProxyServer(s.httpEngine.Http().Engine(), av.mux, s.id, v.String(), mid, s.staticRoutes, s.withoutRoutePrefix, s.tracing)
+		if negotiation != nil {
+			server.InitRpcHttpProxyServer(negotiation, av.mux, s.id, v.String(), mid, s.staticRoutes, s.withoutRoutePrefix, s.tracing)
+		}
+		versions = append(versions, v.String())
+	}
+	if negotiation != nil {
+		s.httpEngine.VersionRouter().Add(negotiation, s.id, versions, s.version.String(), s.ErrorHandler())
+		s.logger.Info("api versions: " + strings.Join(versions, ",") + ", default=" + s.version.String())
 	}
-	server.InitRpcHttpProxyServer(s.httpEngine.Http().Engine(), s.httpEngine.Mux(), s.id, s.version.String(), mid, s.staticRoutes, s.withoutRoutePrefix)
 
+	s.addProbeRoutes(s.httpEngine.Http().Engine())
 	var extRoutes []service.RouteProvider
 	for _, m := range s.extRoutePds {
 		extRoutes = append(extRoutes, m())
 	}
-	server.AddExtRoute(s.httpEngine.Http().Engine(), extRoutes)
+	s.addExtMiddlewares(s.httpEngine.Http().Engine(), func() {
+		s.addExtRoutes(s.httpEngine.Http().Engine(), extRoutes)
+	})
 	s.logger.Info("engine initialized")
 	return nil
 }
 
 func (s *Server) initEngine() error {
-	var mmid []service.MuxRouteHandleFunc
-	for _, m := range s.muxMiddlewarePds {
-		mmid = append(mmid, m())
+	names, err := s.MuxMiddlewares()
+	if err != nil {
+		return s.apiServerError(s.msg("mux middleware order resolve failed"), err)
+	}
+	s.logger.Info("mux middleware order: " + strings.Join(names, ","))
+	for _, v := range s.Versions() {
+		var mmid []service.MuxRouteHandleFunc
+		for _, n := range names {
+			mmid = append(mmid, midswitch.Mux(s.midSwitch(MidMux, n), s.muxMiddlewarePds[n]()))
+		}
+		server.InitMux(s.versions[v].mux, s.mdProvider, mmid, s.errObjProvider, s.app.Debugger())
 	}
-	server.InitMux(s.httpEngine.Mux(), s.mdProvider, mmid, s.errObjProvider, s.app.Debugger())
 
 	for _, m := range s.muxRoutes {
 		if err := m(s.httpEngine.Mux()); err != nil {
@@ -338,22 +562,80 @@ func (s *Server) initRegInfo() {
 }
 
 func (s *Server) RefreshGateway() error {
-	return s.regGateway()
+	s.regLock.Lock()
+	defer s.regLock.Unlock()
+	return s.refreshGateway()
+}
+
+// AddReadyChecker add a readiness checker for the /{id}-readyz probe
+func (s *Server) AddReadyChecker(name string, checker ReadyChecker) {
+	if name != "" && checker != nil {
+		s.readiness.add(name, checker)
+	}
+}
+
+// Ready return the readiness and the state of each check, same as the /{id}-readyz probe
+func (s *Server) Ready(ctx context.Context) (bool, map[string]string) {
+	return s.readiness.check(ctx)
+}
+
+// InFlight return the in-flight request count
+func (s *Server) InFlight() int64 {
+	return s.inflight.Count()
 }
 
+// Release unregister the server, stop accepting new requests and wait the in-flight requests until the drain timeout, then close the http and rpc server.
+// The state moves to draining and then stopped, Done is closed after that
 func (s *Server) Release() {
+	old, err := s.transit("release", StateDraining, StateCreated, StateInitializing, StateRunning)
+	if err != nil {
+		s.logger.Debug(err.Error())
+		return
+	}
 	if s.app.Register() != nil {
+		s.cancelReg()
+		s.stopWatch()
+		s.regLock.Lock()
 		_ = s.register(false)
 		if s.gatewayKey != "" {
 			_ = s.app.Register().Unregister(s.app.Context(), s.gatewayKey)
 			s.gatewayKey = ""
 		}
+		s.regLock.Unlock()
+		s.logger.Debug("unregistered")
+	}
+	s.readiness.set("serving", false)
+	if old == StateRunning {
+		s.drain()
+		s.httpEngine.Http().CloseWithKey(s.id)
+		if s.rpcServer != nil {
+			s.rpcServer.Release()
+		}
+	}
+	if s.tracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err = s.tracing.Flush(ctx); err != nil {
+			s.logger.Warn("tracing flush failed, err=" + err.Error())
+		}
+		cancel()
 	}
 	if s.logger != nil {
 		_ = s.logger.Sync()
 		s.logger.Info("released")
 	}
-	s.running = false
+	_, _ = s.transit("release", StateStopped, StateDraining)
+}
+
+func (s *Server) drain() {
+	s.inflight.Close()
+	s.logger.Info("draining, in-flight=" + strconv.FormatInt(s.inflight.Count(), 10))
+	if s.inflight.Wait(s.drainTimeout, time.Second, func(count int64) {
+		s.logger.Info("draining, in-flight=" + strconv.FormatInt(count, 10))
+	}) {
+		s.logger.Info("drained")
+	} else {
+		s.logger.Warn("drain timeout, in-flight=" + strconv.FormatInt(s.inflight.Count(), 10))
+	}
 }
 
 func (s *Server) addDoc(config *apidoc.Config) {
@@ -396,6 +678,7 @@ func (s *Server) addDoc(config *apidoc.Config) {
 		docRegInfo.ServerInfo.EndType = s.endType.String()
 	}
 	s.docRegInfos = append(s.docRegInfos, docRegInfo)
+	s.docPaths[config.Path] = struct{}{}
 	s.AddRoute(func() service.RouteProvider {
 		docUrl := url.Origin{
 			Protocol: "http",
@@ -413,6 +696,7 @@ func (s *Server) addDoc(config *apidoc.Config) {
 
 func (s *Server) ErrorHandler() func(err error, marshaler runtime.Marshaler, w http.ResponseWriter) {
 	return func(err error, marshaler runtime.Marshaler, w http.ResponseWriter) {
+		errhandler.RecordErrCode(err)
 		errhandler.HandlerErr(err, marshaler, w, nil, s.errObjProvider, s.app.Debugger())
 	}
 }
@@ -453,33 +737,47 @@ func (s *Server) register(reg bool) error {
 	return nil
 }
 
-func (s *Server) watch() {
-	// watch http
+func (s *Server) regGateway() error {
+	return s.putGateway(false)
 }
 
-func (s *Server) regGateway() error {
-	if s.gatewayKeyGen != nil {
-		key, err := s.gatewayKeyGen()
-		if err != nil {
-			return s.apiServerError(s.msg("fetch gateway failed"), err)
-		}
+// refreshGateway register the changed gateway key, and re-put the unchanged one to restore it if the lease expired
+func (s *Server) refreshGateway() error {
+	return s.putGateway(true)
+}
 
-		if key != "" && key != s.gatewayKey {
-			if s.gatewayKey != "" {
-				_ = s.app.Register().Unregister(s.app.Context(), s.gatewayKey)
-			}
-			s.gatewayKey = key
-			if err = s.app.Register().Register(s.app.Context(), s.gatewayKey, url.Origin{
-				Protocol: url.HTTP,
-				Host: url.Host{
-					Ip:   s.httpEngine.Http().Ip(),
-					Port: s.httpEngine.Http().Port(),
-				},
-			}.String(), s.app.RegTtl()); err != nil {
-				return s.apiServerError(s.msg("register gateway failed"), err)
-			}
-		}
+func (s *Server) putGateway(refresh bool) error {
+	if s.gatewayKeyGen == nil {
+		return nil
+	}
+	key, err := s.gatewayKeyGen()
+	if err != nil {
+		return s.apiServerError(s.msg("fetch gateway failed"), err)
+	}
+	if key != s.gatewayKey && s.gatewayKey != "" {
+		_ = s.app.Register().Unregister(s.app.Context(), s.gatewayKey)
+		s.gatewayKey = ""
+	}
+	if key == "" {
+		// no gateway, the readiness does not wait for it
+		s.readiness.remove("gateway")
+		return nil
+	}
+	if key == s.gatewayKey && !refresh {
+		return nil
+	}
+	if err = s.app.Register().Register(s.app.Context(), key, url.Origin{
+		Protocol: url.HTTP,
+		Host: url.Host{
+			Ip:   s.httpEngine.Http().Ip(),
+			Port: s.httpEngine.Http().Port(),
+		},
+	}.String(), s.app.RegTtl()); err != nil {
+		s.readiness.set("gateway", false)
+		return s.apiServerError(s.msg("register gateway failed"), err)
 	}
+	s.gatewayKey = key
+	s.readiness.set("gateway", true)
 	return nil
 }
 
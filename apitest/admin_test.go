@@ -0,0 +1,102 @@
+package apitest
+
+import (
+	"github.com/obnahsgnaw/api"
+	"github.com/obnahsgnaw/application"
+	"net/http"
+	"testing"
+)
+
+func newAdmin(t *testing.T, o ...Option) *Harness {
+	o = append(o, Options(api.AdminApi("secret")), Setup(func(s *api.Server) {
+		_ = s.AddMuxRoute("GET", "/v1/{name}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			_, _ = w.Write([]byte(pathParams["name"]))
+		})
+	}))
+	return New(t, "demo", o...)
+}
+
+func setMiddleware(h *Harness, name string, update map[string]interface{}) *Response {
+	return h.Request(http.MethodPut, "/demo-admin/middlewares/"+name).JSON(update).Do()
+}
+
+func TestAdminToggleSign(t *testing.T) {
+	h := newAdmin(t, WithSign())
+	h.Get("/v1/demo/hello").App("app1").Do().ExpectStatus(http.StatusBadRequest)
+
+	setMiddleware(h, "sign", map[string]interface{}{"enabled": false}).ExpectStatus(http.StatusOK)
+	h.Get("/v1/demo/hello").App("app1").Do().ExpectStatus(http.StatusOK)
+
+	setMiddleware(h, "sign", map[string]interface{}{"enabled": true}).ExpectStatus(http.StatusOK)
+	h.Get("/v1/demo/hello").App("app1").Do().ExpectStatus(http.StatusBadRequest)
+}
+
+func TestAdminToggleCrypt(t *testing.T) {
+	h := newAdmin(t, WithCrypt())
+	rp := h.Get("/v1/demo/hello").Iv("k").Do().ExpectStatus(http.StatusOK)
+	if string(rp.Body) == "hello" {
+		t.Fatal("response not encrypted")
+	}
+
+	setMiddleware(h, "crypt", map[string]interface{}{"enabled": false}).ExpectStatus(http.StatusOK)
+	if rp = h.Get("/v1/demo/hello").Iv("k").Do().ExpectStatus(http.StatusOK); string(rp.Body) != "hello" {
+		t.Fatal("disabled crypt still encrypts: " + string(rp.Body))
+	}
+
+	setMiddleware(h, "crypt", map[string]interface{}{"enabled": true}).ExpectStatus(http.StatusOK)
+	if rp = h.Get("/v1/demo/hello").Iv("k").Do().ExpectStatus(http.StatusOK); string(rp.Body) == "hello" {
+		t.Fatal("enabled crypt not encrypts")
+	}
+}
+
+func TestAdminIgnores(t *testing.T) {
+	h := newAdmin(t, WithSign())
+	var states []api.MiddlewareState
+	rp := setMiddleware(h, "sign", map[string]interface{}{"ignores": []string{"get /v1/demo/hello"}}).ExpectStatus(http.StatusOK)
+	if err := rp.Decode(&states); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || len(states[0].Ignores) != 1 || states[0].Ignores[0] != "GET /v1/hello" {
+		t.Fatal("ignore rule not normalized: " + string(rp.Body))
+	}
+	h.Get("/v1/demo/hello").App("app1").Do().ExpectStatus(http.StatusOK)
+	h.Get("/v1/demo/other").App("app1").Do().ExpectStatus(http.StatusBadRequest)
+
+	setMiddleware(h, "sign", map[string]interface{}{"ignores": []string{"hello"}}).ExpectStatus(http.StatusBadRequest)
+	setMiddleware(h, "unknown", map[string]interface{}{"enabled": false}).ExpectStatus(http.StatusNotFound)
+
+	rp = h.Get("/demo-admin/middlewares").Do().ExpectStatus(http.StatusOK)
+	states = nil
+	if err := rp.Decode(&states); err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range states {
+		if st.Name == "sign" {
+			if st.Kind != api.MidHttp || !st.Enabled || len(st.Ignores) != 1 {
+				t.Fatal("unexpected sign state: " + string(rp.Body))
+			}
+			return
+		}
+	}
+	t.Fatal("sign state not reported: " + string(rp.Body))
+}
+
+func TestAdminGuard(t *testing.T) {
+	debug := true
+	h := newAdmin(t)
+	h.App.With(application.Debug(func() bool {
+		return debug
+	}))
+	h.Get("/demo-admin/middlewares").Do().ExpectStatus(http.StatusOK)
+
+	debug = false
+	h.Get("/demo-admin/middlewares").Do().ExpectStatus(http.StatusForbidden)
+	h.Get("/demo-admin/middlewares").Header("X-Admin-Token", "wrong").Do().ExpectStatus(http.StatusForbidden)
+	h.Get("/demo-admin/middlewares").Header("X-Admin-Token", "secret").Do().ExpectStatus(http.StatusOK)
+
+	h2 := New(t, "demo2", Options(api.AdminApi("")))
+	h2.App.With(application.Debug(func() bool {
+		return false
+	}))
+	h2.Get("/demo2-admin/middlewares").Do().ExpectStatus(http.StatusNotFound)
+}
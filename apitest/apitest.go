@@ -0,0 +1,166 @@
+package apitest
+
+import (
+	"github.com/obnahsgnaw/api"
+	"github.com/obnahsgnaw/api/engine"
+	"github.com/obnahsgnaw/api/service/authedapp"
+	"github.com/obnahsgnaw/api/service/autheduser"
+	"github.com/obnahsgnaw/api/service/crypt"
+	"github.com/obnahsgnaw/api/service/perm"
+	"github.com/obnahsgnaw/api/service/sign"
+	"github.com/obnahsgnaw/application"
+	"github.com/obnahsgnaw/application/endtype"
+	"github.com/obnahsgnaw/application/pkg/url"
+	engine2 "github.com/obnahsgnaw/http/engine"
+	"io"
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+// Harness an in-process api server served by an httptest server, without registry and rpc
+type Harness struct {
+	t       testing.TB
+	id      string
+	App     *application.Application
+	Engine  *engine.MuxHttp
+	Server  *api.Server
+	HTTP    *httptest.Server
+	Apps    *AppProvider
+	Users   *UserProvider
+	Perms   *PermProvider
+	Signs   *SignProvider
+	Crypts  *CryptProvider
+	options []api.Option
+	setups  []func(s *api.Server)
+	endType endtype.EndType
+}
+
+type Option func(h *Harness)
+
+// Options add api server options
+func Options(o ...api.Option) Option {
+	return func(h *Harness) {
+		h.options = append(h.options, o...)
+	}
+}
+
+// Setup run fn before the server initialized, to register services, routes and so on
+func Setup(fn func(s *api.Server)) Option {
+	return func(h *Harness) {
+		if fn != nil {
+			h.setups = append(h.setups, fn)
+		}
+	}
+}
+
+// EndType set the server end type, default backend
+func EndType(et endtype.EndType) Option {
+	return func(h *Harness) {
+		h.endType = et
+	}
+}
+
+// WithApp enable the app middleware with the fake app provider
+func WithApp(o ...authedapp.Option) Option {
+	return func(h *Harness) {
+		h.options = append(h.options, api.AppMiddleware(authedapp.New(h.id, h.Apps, o...)))
+	}
+}
+
+// WithAuth enable the auth middleware with the fake user provider
+func WithAuth(o ...autheduser.Option) Option {
+	return func(h *Harness) {
+		h.options = append(h.options, api.AuthMiddleware(autheduser.New(h.Users, o...)))
+	}
+}
+
+// WithPerm enable the perm middleware with the fake perm provider
+func WithPerm(o ...perm.Option) Option {
+	return func(h *Harness) {
+		h.options = append(h.options, api.PermMiddleware(perm.New(h.Perms, o...)))
+	}
+}
+
+// WithSign enable the sign middleware with the fake sign provider
+func WithSign(o ...sign.Option) Option {
+	return func(h *Harness) {
+		h.options = append(h.options, api.SignMiddleware(sign.New(h.Signs, o...)))
+	}
+}
+
+// WithCrypt enable the crypt middleware with the fake crypt provider
+func WithCrypt(o ...crypt.Option) Option {
+	return func(h *Harness) {
+		h.options = append(h.options, api.CryptMiddleware(crypt.New(h.Crypts, o...)))
+	}
+}
+
+// New build and serve an api server with the id, it is released when the test finished
+func New(t testing.TB, id string, o ...Option) *Harness {
+	t.Helper()
+	h := &Harness{
+		t:       t,
+		id:      id,
+		App:     application.New("apitest"),
+		Apps:    NewAppProvider(),
+		Users:   NewUserProvider(),
+		Perms:   NewPermProvider(),
+		Signs:   NewSignProvider(),
+		Crypts:  NewCryptProvider(),
+		endType: endtype.Backend,
+	}
+	h.App.With(application.Debug(func() bool {
+		return true
+	}))
+	for _, op := range o {
+		op(h)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatal("apitest: get free port failed, err=" + err.Error())
+	}
+	h.Engine, err = api.NewEngine(h.App, url.Host{Ip: "127.0.0.1", Port: port}, &engine2.Config{
+		Name:         "apitest-" + id,
+		AccessWriter: io.Discard,
+		ErrWriter:    io.Discard,
+	})
+	if err != nil {
+		t.Fatal("apitest: new engine failed, err=" + err.Error())
+	}
+	h.Server = api.New(h.App, h.Engine, id, id, h.endType, 1, h.options...)
+	for _, fn := range h.setups {
+		fn(h.Server)
+	}
+
+	handler, err := h.Server.Handler()
+	if err != nil {
+		h.Engine.Http().Close()
+		t.Fatal("apitest: init server failed, err=" + err.Error())
+	}
+	h.HTTP = httptest.NewServer(handler)
+	t.Cleanup(h.Close)
+	return h
+}
+
+// URL return the base url of the server
+func (h *Harness) URL() string {
+	return h.HTTP.URL
+}
+
+// Close the server, called automatically when the test finished
+func (h *Harness) Close() {
+	h.HTTP.Close()
+	h.Server.Release()
+	h.Engine.Http().Close()
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,54 @@
+package apitest
+
+import (
+	"errors"
+	"github.com/obnahsgnaw/api"
+	"net/http"
+	"testing"
+)
+
+func TestHarness(t *testing.T) {
+	h := New(t, "demo", WithApp(), WithAuth(), Setup(func(s *api.Server) {
+		s.AddMuxRoute("GET", "/v1/{name}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			_, _ = w.Write([]byte(pathParams["name"] + ":" + r.Header.Get("X-User-Id")))
+		})
+	}))
+	h.Apps.Add(&App{ID: 1, AppID: "app1"})
+	h.Users.Add(&User{ID: 1, UID: "u1"}, "token1")
+
+	rp := h.Get("/v1/demo/hello").App("app2").Do().ExpectStatus(http.StatusUnauthorized)
+	if p, err := rp.Err(); err != nil || p.Code == 0 || p.Message == "" {
+		t.Fatal("unexpected error body: " + string(rp.Body))
+	}
+
+	rp = h.Get("/v1/demo/hello").App("app1").Token("token1").Do().ExpectStatus(http.StatusOK)
+	if string(rp.Body) != "hello:u1" {
+		t.Fatal("unexpected body: " + string(rp.Body))
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	h := New(t, "demo")
+	var states []string
+	h.Server.OnStateChange(func(from, to api.State) {
+		states = append(states, to.String())
+	})
+	if h.Server.State() != api.StateRunning {
+		t.Fatal("unexpected state: " + h.Server.State().String())
+	}
+
+	var stateErr *api.StateError
+	if err := h.Server.AddMuxRoute("GET", "/v1/late", nil); !errors.As(err, &stateErr) {
+		t.Fatal("late route not rejected")
+	}
+
+	h.Close()
+	select {
+	case <-h.Server.Done():
+	default:
+		t.Fatal("done not closed")
+	}
+	if len(states) != 2 || states[0] != "draining" || states[1] != "stopped" {
+		t.Fatal("unexpected transitions")
+	}
+}
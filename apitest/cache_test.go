@@ -0,0 +1,44 @@
+package apitest
+
+import (
+	"github.com/obnahsgnaw/api"
+	"github.com/obnahsgnaw/api/service/httpcache"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCacheAfterPerm(t *testing.T) {
+	var calls int
+	m := httpcache.New(httpcache.ResponseCache(httpcache.NewMemoryCache(10), time.Minute), httpcache.CachePrivate())
+	h := New(t, "demo", WithApp(), WithAuth(), WithPerm(), Options(api.HttpCache(m)), Setup(func(s *api.Server) {
+		_ = s.AddMuxRoute("GET", "/v1/{name}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			calls++
+			_, _ = w.Write([]byte(pathParams["name"]))
+		})
+	}))
+	h.Apps.Add(&App{ID: 1, AppID: "app1"})
+	h.Users.Add(&User{ID: 1, UID: "u1"}, "token1")
+	h.Perms.AllowAll(true)
+
+	rp := h.Get("/v1/demo/hello").App("app1").Token("token1").Do().ExpectStatus(http.StatusOK)
+	if rp.Header.Get("X-Cache") != "MISS" {
+		t.Fatal("response not cached")
+	}
+	rp = h.Get("/v1/demo/hello").App("app1").Token("token1").Do().ExpectStatus(http.StatusOK)
+	if rp.Header.Get("X-Cache") != "HIT" || string(rp.Body) != "hello" || calls != 1 {
+		t.Fatal("cached response not served", rp.Header.Get("X-Cache"), calls)
+	}
+	rp = h.Get("/v1/demo/hello").App("app1").Token("token1").Header("If-None-Match", rp.Header.Get("ETag")).Do()
+	if rp.StatusCode != http.StatusNotModified {
+		t.Fatal("conditional request of a cached response not answered with 304", rp.StatusCode)
+	}
+
+	h.Perms.AllowAll(false)
+	h.Get("/v1/demo/hello").App("app1").Token("token1").Do().ExpectStatus(http.StatusForbidden)
+	h.Users.Revoke("token1")
+	h.Get("/v1/demo/hello").App("app1").Token("token1").Do().ExpectStatus(http.StatusUnauthorized)
+	if calls != 1 {
+		t.Fatal("rejected request reached the handler")
+	}
+}
@@ -0,0 +1,44 @@
+package apitest
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/obnahsgnaw/api"
+	"github.com/obnahsgnaw/api/service"
+	"github.com/obnahsgnaw/api/service/cors"
+	"net/http"
+	"testing"
+)
+
+func TestExtMiddlewares(t *testing.T) {
+	var engineMids int
+	h := New(t, "demo", Options(api.Cors(cors.Config{AllowOrigins: []string{"https://a.com"}})), Setup(func(s *api.Server) {
+		engineMids = len(s.Engine().Http().Engine().Handlers)
+		_ = s.AddRoute(func() service.RouteProvider {
+			return func(e *gin.Engine) {
+				e.GET("/demo-ext", func(c *gin.Context) {
+					c.String(http.StatusOK, "ext")
+				})
+			}
+		})
+	}))
+	e := h.Engine.Http().Engine()
+	if len(e.Handlers) != engineMids {
+		t.Fatal("ext middlewares added to the shared engine")
+	}
+	e.GET("/other", func(c *gin.Context) {
+		c.String(http.StatusOK, "other")
+	})
+
+	rp := h.Get("/demo-ext").Header("Origin", "https://a.com").Do().ExpectStatus(http.StatusOK)
+	if rp.Header.Get("Access-Control-Allow-Origin") != "https://a.com" {
+		t.Fatal("ext route not wrapped by cors")
+	}
+	rp = h.Request(http.MethodOptions, "/demo-ext").Header("Origin", "https://a.com").Header("Access-Control-Request-Method", "GET").Do()
+	if rp.StatusCode != http.StatusNoContent || rp.Header.Get("Access-Control-Allow-Origin") != "https://a.com" {
+		t.Fatal("ext route preflight not answered", rp.StatusCode)
+	}
+	rp = h.Get("/other").Header("Origin", "https://a.com").Do().ExpectStatus(http.StatusOK)
+	if rp.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("route of the engine wrapped by the server middlewares")
+	}
+}
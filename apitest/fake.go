@@ -0,0 +1,284 @@
+package apitest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/obnahsgnaw/api/service/authedapp"
+	"github.com/obnahsgnaw/api/service/autheduser"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// App in-memory authedapp.App
+type App struct {
+	ID         uint32
+	AppID      string
+	AppName    string
+	IsBackend  bool
+	Scopes     []string
+	IsManage   bool
+	Attributes map[string]string
+}
+
+func (a *App) Id() uint32 {
+	return a.ID
+}
+
+func (a *App) AppId() string {
+	return a.AppID
+}
+
+func (a *App) Name() string {
+	return a.AppName
+}
+
+func (a *App) Backend() bool {
+	return a.IsBackend
+}
+
+func (a *App) Scope() []string {
+	return a.Scopes
+}
+
+func (a *App) Manage() bool {
+	return a.IsManage
+}
+
+func (a *App) Attr(attr string) (string, bool) {
+	v, ok := a.Attributes[attr]
+	return v, ok
+}
+
+func (a *App) Attrs() map[string]string {
+	return a.Attributes
+}
+
+func (a *App) DefaultAttr(attr, defVal string) string {
+	if v, ok := a.Attributes[attr]; ok {
+		return v
+	}
+	return defVal
+}
+
+// AppProvider in-memory authedapp.AppProvider, only added apps are valid
+type AppProvider struct {
+	mu   sync.RWMutex
+	apps map[string]*App
+}
+
+func NewAppProvider() *AppProvider {
+	return &AppProvider{apps: make(map[string]*App)}
+}
+
+// Add an app, keyed by its app id
+func (p *AppProvider) Add(app *App) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.apps[app.AppID] = app
+}
+
+func (p *AppProvider) GetValidApp(_, id, _ string, _ bool) (authedapp.App, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if app, ok := p.apps[id]; ok {
+		return app, nil
+	}
+	return nil, errors.New("app[" + id + "] invalid")
+}
+
+// User in-memory autheduser.User
+type User struct {
+	ID         uint32
+	UID        string
+	UserName   string
+	IsBackend  bool
+	Attributes map[string]string
+}
+
+func (u *User) Id() uint32 {
+	return u.ID
+}
+
+func (u *User) Uid() string {
+	return u.UID
+}
+
+func (u *User) Name() string {
+	return u.UserName
+}
+
+func (u *User) Backend() bool {
+	return u.IsBackend
+}
+
+func (u *User) Attr(attr string) (string, bool) {
+	v, ok := u.Attributes[attr]
+	return v, ok
+}
+
+func (u *User) Attrs() map[string]string {
+	return u.Attributes
+}
+
+func (u *User) DefaultAttr(attr, defVal string) string {
+	if v, ok := u.Attributes[attr]; ok {
+		return v
+	}
+	return defVal
+}
+
+// UserProvider in-memory autheduser.UserProvider, tokens map to users
+type UserProvider struct {
+	mu     sync.RWMutex
+	users  map[string]*User
+	tokens map[string]string // token => uid
+}
+
+func NewUserProvider() *UserProvider {
+	return &UserProvider{users: make(map[string]*User), tokens: make(map[string]string)}
+}
+
+// Add a user with its valid tokens
+func (p *UserProvider) Add(user *User, tokens ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.users[user.UID] = user
+	for _, t := range tokens {
+		p.tokens[t] = user.UID
+	}
+}
+
+// Revoke the tokens
+func (p *UserProvider) Revoke(tokens ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, t := range tokens {
+		delete(p.tokens, t)
+	}
+}
+
+func (p *UserProvider) GetTokenUser(_, _, token string) (autheduser.User, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if uid, ok := p.tokens[token]; ok {
+		return p.users[uid], nil
+	}
+	return nil, errors.New("token invalid")
+}
+
+func (p *UserProvider) GetIdUser(_, _, uid string) (autheduser.User, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if u, ok := p.users[uid]; ok {
+		return u, nil
+	}
+	return nil, errors.New("user[" + uid + "] not found")
+}
+
+// PermProvider in-memory perm.Provider, denies all until allowed
+type PermProvider struct {
+	mu       sync.RWMutex
+	allowAll bool
+	allowed  map[string]struct{} // uid method pattern
+}
+
+func NewPermProvider() *PermProvider {
+	return &PermProvider{allowed: make(map[string]struct{})}
+}
+
+// AllowAll allow or deny every request
+func (p *PermProvider) AllowAll(allow bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.allowAll = allow
+}
+
+// Allow the user to access the pattern with the method
+func (p *PermProvider) Allow(uid, method, pattern string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.allowed[permKey(uid, method, pattern)] = struct{}{}
+}
+
+func (p *PermProvider) Can(_, _, uid, method, pattern string) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.allowAll {
+		return nil
+	}
+	if _, ok := p.allowed[permKey(uid, method, pattern)]; ok {
+		return nil
+	}
+	return errors.New("permission denied")
+}
+
+func permKey(uid, method, pattern string) string {
+	return uid + " " + strings.ToUpper(method) + " " + pattern
+}
+
+// SignProvider sign.Provider with sha256(appid:uid:method:uri:timestamp:nonce)
+type SignProvider struct {
+	mu    sync.Mutex
+	nonce int64
+}
+
+func NewSignProvider() *SignProvider {
+	return &SignProvider{}
+}
+
+func (p *SignProvider) Validate(appid, uid, method, uri, signature, timestamp, nonce string) error {
+	if signature != p.sum(appid, uid, method, uri, timestamp, nonce) {
+		return errors.New("signature invalid")
+	}
+	return nil
+}
+
+func (p *SignProvider) Generate(appid, uid, method, uri string) (signature, timestamp, nonce string, err error) {
+	p.mu.Lock()
+	p.nonce++
+	nonce = strconv.FormatInt(p.nonce, 10)
+	p.mu.Unlock()
+	timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+	signature = p.sum(appid, uid, method, uri, timestamp, nonce)
+	return
+}
+
+// Sign return the signature header value: sign-timestamp-nonce
+func (p *SignProvider) Sign(appid, uid, method, uri string) string {
+	s, t, n, _ := p.Generate(appid, uid, method, uri)
+	return strings.Join([]string{s, t, n}, "-")
+}
+
+func (p *SignProvider) sum(segments ...string) string {
+	h := sha256.Sum256([]byte(strings.Join(segments, ":")))
+	return hex.EncodeToString(h[:])
+}
+
+// CryptProvider crypt.Provider xor the data with the iv, an empty iv keeps the data as it is
+type CryptProvider struct{}
+
+func NewCryptProvider() *CryptProvider {
+	return &CryptProvider{}
+}
+
+func (p *CryptProvider) Encrypt(_, _ string, iv []byte, data []byte) ([]byte, error) {
+	return xor(iv, data), nil
+}
+
+func (p *CryptProvider) Decrypt(_, _ string, iv []byte, data []byte) ([]byte, error) {
+	return xor(iv, data), nil
+}
+
+func xor(iv, data []byte) []byte {
+	if len(iv) == 0 {
+		return data
+	}
+	out := make([]byte, len(data))
+	for i := range data {
+		out[i] = data[i] ^ iv[i%len(iv)]
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package apitest
+
+import (
+	"github.com/obnahsgnaw/api"
+	"github.com/obnahsgnaw/api/service/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newMetrics(t *testing.T, reg *prometheus.Registry, o ...api.Option) *Harness {
+	o = append(o, api.Metrics(metrics.New(metrics.Registry(reg, reg))))
+	return New(t, "demo", WithApp(), Options(o...), Setup(func(s *api.Server) {
+		_ = s.AddMuxRoute("GET", "/v1/users/{id}", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			_, _ = w.Write([]byte(pathParams["id"]))
+		})
+	}))
+}
+
+func TestMetricsLabels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	h := newMetrics(t, reg)
+	h.Apps.Add(&App{ID: 1, AppID: "app1"})
+	for _, id := range []string{"1", "2", "3"} {
+		h.Get("/v1/demo/users/" + id).App("app1").Do().ExpectStatus(http.StatusOK)
+	}
+	for _, path := range []string{"/v1/demo/unknown/1", "/v1/demo/unknown/2", "/nope/1", "/nope/2"} {
+		h.Get(path).App("app1").Do()
+	}
+	h.Get("/v1/demo/users/1").App("app2").Do().ExpectStatus(http.StatusUnauthorized)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var series, rejected int
+	for _, f := range families {
+		switch f.GetName() {
+		case "api_requests_total":
+			for _, m := range f.GetMetric() {
+				series++
+				for _, l := range m.GetLabel() {
+					if strings.Contains(l.GetValue(), "unknown/") || strings.Contains(l.GetValue(), "nope/") || strings.HasSuffix(l.GetValue(), "users/1") {
+						t.Fatal("request path in the labels: " + l.GetName() + "=" + l.GetValue())
+					}
+					if l.GetName() == "pattern" && l.GetValue() == "/v1/users/{id}" && m.GetCounter().GetValue() < 3 {
+						t.Fatal("requests of the pattern not counted together")
+					}
+				}
+			}
+		case "api_middleware_rejections_total":
+			rejected = len(f.GetMetric())
+		}
+	}
+	// the pattern ok, the pattern rejected by app, the unrouted gateway and the unrouted engine requests
+	if series == 0 || series > 4 {
+		t.Fatal("unexpected series count", series)
+	}
+	if rejected != 1 {
+		t.Fatal("app rejection not counted")
+	}
+}
+
+func TestMetricsRoute(t *testing.T) {
+	h := newMetrics(t, prometheus.NewRegistry())
+	h.Get("/metrics").Do().ExpectStatus(http.StatusNotFound)
+
+	h = newMetrics(t, prometheus.NewRegistry(), api.MetricsRoute(""))
+	h.Apps.Add(&App{ID: 1, AppID: "app1"})
+	h.Get("/v1/demo/users/1").App("app1").Do().ExpectStatus(http.StatusOK)
+	rp := h.Get("/metrics").Do().ExpectStatus(http.StatusOK)
+	if !strings.Contains(string(rp.Body), `api_requests_total{err_code="",kind="gateway",method="GET",pattern="/v1/users/{id}"`) {
+		t.Fatal("gateway requests not exposed: " + string(rp.Body))
+	}
+	if !strings.Contains(string(rp.Body), "api_registry_status") {
+		t.Fatal("registry status not exposed")
+	}
+}
@@ -0,0 +1,179 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/obnahsgnaw/api/pkg/errobj"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// Request a fluent request to the harness server
+type Request struct {
+	h      *Harness
+	method string
+	path   string
+	header http.Header
+	body   []byte
+	sign   bool
+	err    error
+}
+
+// Response the recorded response
+type Response struct {
+	t          testing.TB
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Request start a request with the method and the path, the path is relative to the server url
+func (h *Harness) Request(method, path string) *Request {
+	return &Request{
+		h:      h,
+		method: method,
+		path:   path,
+		header: make(http.Header),
+	}
+}
+
+func (h *Harness) Get(path string) *Request {
+	return h.Request(http.MethodGet, path)
+}
+
+func (h *Harness) Post(path string) *Request {
+	return h.Request(http.MethodPost, path)
+}
+
+func (h *Harness) Put(path string) *Request {
+	return h.Request(http.MethodPut, path)
+}
+
+func (h *Harness) Patch(path string) *Request {
+	return h.Request(http.MethodPatch, path)
+}
+
+func (h *Harness) Delete(path string) *Request {
+	return h.Request(http.MethodDelete, path)
+}
+
+// Header set a request header
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// App set the X-App-Id header
+func (r *Request) App(appId string) *Request {
+	return r.Header("X-App-Id", appId)
+}
+
+// User set the X-User-Id header
+func (r *Request) User(uid string) *Request {
+	return r.Header("X-User-Id", uid)
+}
+
+// Token set the Authorization header
+func (r *Request) Token(token string) *Request {
+	return r.Header("Authorization", token)
+}
+
+// Iv set the X-User-Iv header for the crypt middleware
+func (r *Request) Iv(iv string) *Request {
+	return r.Header("X-User-Iv", iv)
+}
+
+// Signed sign the request with the fake sign provider when sent
+func (r *Request) Signed() *Request {
+	r.sign = true
+	return r
+}
+
+// Body set the raw request body
+func (r *Request) Body(body []byte) *Request {
+	r.body = body
+	return r
+}
+
+// JSON set the json encoded request body and the content type
+func (r *Request) JSON(v interface{}) *Request {
+	r.body, r.err = json.Marshal(v)
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// Do send the request, the test fails if the request can not be sent
+func (r *Request) Do() *Response {
+	t := r.h.t
+	t.Helper()
+	if r.err != nil {
+		t.Fatal("apitest: build request failed, err=" + r.err.Error())
+	}
+	rq, err := http.NewRequest(r.method, r.h.URL()+r.path, bytes.NewReader(r.body))
+	if err != nil {
+		t.Fatal("apitest: build request failed, err=" + err.Error())
+	}
+	for k, v := range r.header {
+		rq.Header[k] = v
+	}
+	if r.sign {
+		rq.Header.Set("X-Signature", r.h.Signs.Sign(rq.Header.Get("X-App-Id"), rq.Header.Get("X-User-Id"), r.method, rq.URL.Path))
+	}
+	rp, err := r.h.HTTP.Client().Do(rq)
+	if err != nil {
+		t.Fatal("apitest: request failed, err=" + err.Error())
+	}
+	defer rp.Body.Close()
+	body, err := io.ReadAll(rp.Body)
+	if err != nil {
+		t.Fatal("apitest: read response failed, err=" + err.Error())
+	}
+	return &Response{
+		t:          t,
+		StatusCode: rp.StatusCode,
+		Header:     rp.Header,
+		Body:       body,
+	}
+}
+
+// Decode the json response body into v
+func (r *Response) Decode(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Err decode the errobj.Param error body, return error if the response is not an error
+func (r *Response) Err() (*errobj.Param, error) {
+	if r.StatusCode < http.StatusBadRequest {
+		return nil, errors.New("response status " + strconv.Itoa(r.StatusCode) + " is not an error")
+	}
+	var p errobj.Param
+	if err := r.Decode(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ExpectStatus fail the test if the status code not matched
+func (r *Response) ExpectStatus(code int) *Response {
+	r.t.Helper()
+	if r.StatusCode != code {
+		r.t.Fatal("apitest: expect status " + strconv.Itoa(code) + ", got " + strconv.Itoa(r.StatusCode) + ", body=" + string(r.Body))
+	}
+	return r
+}
+
+// ExpectErrCode fail the test if the error code of the errobj.Param body not matched
+func (r *Response) ExpectErrCode(code uint32) *Response {
+	r.t.Helper()
+	p, err := r.Err()
+	if err != nil {
+		r.t.Fatal("apitest: decode error body failed, err=" + err.Error() + ", body=" + string(r.Body))
+	}
+	if p.Code != code {
+		r.t.Fatal("apitest: expect error code " + strconv.FormatUint(uint64(code), 10) + ", got " + strconv.FormatUint(uint64(p.Code), 10) + ", message=" + p.Message)
+	}
+	return r
+}
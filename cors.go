@@ -0,0 +1,34 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/obnahsgnaw/api/internal/middleware/corsmid"
+	"net/http"
+)
+
+func (s *Server) corsMid() gin.HandlerFunc {
+	expose := []string{"X-Request-Id"}
+	if s.signHeaderKey != "" {
+		expose = append(expose, s.signHeaderKey)
+	}
+	return corsmid.NewCorsMid(s.cors, expose, func(msg string) {
+		s.logger.Debug(msg)
+	})
+}
+
+// addCorsPreflights answer the preflight of the ext paths without an OPTIONS route by the cors middleware
+func (s *Server) addCorsPreflights(e *gin.Engine, paths map[string]struct{}) {
+	options := make(map[string]struct{})
+	for _, r := range s.extRouteInfos {
+		if r.method == http.MethodOptions {
+			options[r.pattern] = struct{}{}
+		}
+	}
+	for p := range paths {
+		if _, ok := options[p]; !ok {
+			e.OPTIONS(p, func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			})
+		}
+	}
+}
@@ -6,12 +6,15 @@ import (
 	"github.com/obnahsgnaw/application/pkg/url"
 	"github.com/obnahsgnaw/http"
 	"github.com/obnahsgnaw/http/engine"
+	"sync"
 )
 
 type MuxHttp struct {
-	e    *http.Http
-	mux  *runtime.ServeMux
-	tags map[string]struct{}
+	e        *http.Http
+	mux      *runtime.ServeMux
+	tags     map[string]struct{}
+	mu       sync.Mutex
+	versions *server.VersionRouter
 }
 
 func New(e *http.Http) *MuxHttp {
@@ -46,3 +49,13 @@ func (s *MuxHttp) Tagged(name string) bool {
 	_, ok := s.tags[name]
 	return ok
 }
+
+// VersionRouter return the version router shared by the servers of the engine, installed on the first call
+func (s *MuxHttp) VersionRouter() *server.VersionRouter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.versions == nil {
+		s.versions = server.NewVersionRouter(s.e.Engine())
+	}
+	return s.versions
+}
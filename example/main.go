@@ -32,7 +32,7 @@ func main() {
 	r, _ := regCenter.NewEtcdRegister([]string{"127.0.0.1:2379"}, time.Second*5)
 	app.With(application.Register(r, 5))
 
-	//jwt.SetKeyPrefix()
+	//store := jwt.NewRedisKeyStore(rds, "auth")
 
 	e, _ := api.NewEngine(app, url.Host{Ip: "127.0.0.1", Port: 8001}, &engine2.Config{
 		Name: endtype.Backend.String() + "-auth",
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/obnahsgnaw/api/internal/middleware/compressmid"
+	"github.com/obnahsgnaw/api/internal/middleware/drainmid"
+	"github.com/obnahsgnaw/api/internal/middleware/metricsmid"
+	"github.com/obnahsgnaw/api/internal/midswitch"
+	"github.com/obnahsgnaw/api/pkg/jwt"
+	"github.com/obnahsgnaw/api/service"
+	"net/http"
+)
+
+// ext middlewares, added to the pipeline for the gateway routes and applied to the ext routes as well
+const (
+	extMidMetrics  = "metrics"
+	extMidCors     = "cors"
+	extMidCompress = "compress"
+)
+
+// addExtMiddlewares apply the drain middleware and the ext middlewares to the ext routes added by addRoutes. They are prepended to the
+// routes added meanwhile like a route group, the other routes of the engine shared with the other servers are left as is
+func (s *Server) addExtMiddlewares(e *gin.Engine, addRoutes func()) {
+	mids := []gin.HandlerFunc{s.drainMid()}
+	for _, n := range []string{extMidMetrics, extMidCors, extMidCompress} {
+		if _, ok := s.middlewarePds[n]; ok {
+			mids = append(mids, midswitch.Gin(s.midSwitch(MidHttp, n), s.middlewarePds[n]()))
+		}
+	}
+	base := e.Handlers
+	e.Handlers = append(append(gin.HandlersChain{}, base...), mids...)
+	defer func() {
+		// keep the engine middlewares the providers used
+		e.Handlers = append(append(gin.HandlersChain{}, base...), e.Handlers[len(base)+len(mids):]...)
+	}()
+	addRoutes()
+	if s.cors != nil {
+		paths := make(map[string]struct{})
+		for _, r := range s.extRouteInfos {
+			if r.kind != RouteProbe {
+				paths[r.pattern] = struct{}{}
+			}
+		}
+		s.addCorsPreflights(e, paths)
+	}
+}
+
+func (s *Server) drainMid() gin.HandlerFunc {
+	return drainmid.NewDrainMid(s.inflight, func(msg string) {
+		s.logger.Debug(msg)
+	}, s.ErrorHandler())
+}
+
+func (s *Server) metricsMid() gin.HandlerFunc {
+	return metricsmid.NewMetricsMid(s.metrics, func(msg string) {
+		s.logger.Debug(msg)
+	})
+}
+
+// metricsRoute expose the metrics, skipped if the Metrics option not set
+func (s *Server) metricsRoute(path string) service.RouteProvider {
+	return func(engine *gin.Engine) {
+		if s.metrics == nil {
+			s.logger.Warn("metrics route skipped, metrics not enabled")
+			return
+		}
+		engine.GET(path, gin.WrapH(s.metrics.Handler()))
+	}
+}
+
+// jwksRoute publish the keys of the keyring not retired, read each time to follow the rotations
+func (s *Server) jwksRoute(ring *jwt.Keyring, path string) service.RouteProvider {
+	return func(engine *gin.Engine) {
+		engine.GET(path, func(c *gin.Context) {
+			c.Header("Cache-Control", "public, max-age=300")
+			c.JSON(http.StatusOK, ring.Jwks())
+		})
+	}
+}
+
+func (s *Server) compressMid() gin.HandlerFunc {
+	return compressmid.NewCompressMid(s.compress, func(msg string) {
+		s.logger.Debug(msg)
+	}, s.ErrorHandler())
+}
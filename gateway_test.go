@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/obnahsgnaw/application"
+	"github.com/obnahsgnaw/application/endtype"
+	"github.com/obnahsgnaw/application/pkg/url"
+	"github.com/obnahsgnaw/application/service/regCenter"
+	engine2 "github.com/obnahsgnaw/http/engine"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type register = regCenter.Register
+
+// fakeRegister the registry of the gateway key, failing the first fails registrations
+type fakeRegister struct {
+	register
+	fails int
+	keys  map[string]string
+}
+
+func (r *fakeRegister) Register(_ context.Context, key, val string, _ int64) error {
+	if r.fails > 0 {
+		r.fails--
+		return errors.New("registry unavailable")
+	}
+	r.keys[key] = val
+	return nil
+}
+
+func (r *fakeRegister) Unregister(_ context.Context, key string) error {
+	delete(r.keys, key)
+	return nil
+}
+
+// newRegServer return a server of the registry initialized by Handler, the registration is driven by the tests
+func newRegServer(t *testing.T, reg *fakeRegister, key *string, o ...Option) (*Server, http.Handler) {
+	app := application.New("test")
+	app.With(application.Register(reg, 5))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+	e, err := NewEngine(app, url.Host{Ip: "127.0.0.1", Port: port}, &engine2.Config{
+		Name:         "gateway-test",
+		AccessWriter: io.Discard,
+		ErrWriter:    io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Http().Close)
+	o = append(o, Gateway(func() (string, error) {
+		return *key, nil
+	}))
+	s := New(app, e, "demo", "demo", endtype.Backend, 1, o...)
+	h, err := s.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Release)
+	return s, h
+}
+
+func readyz(h http.Handler) int {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/demo-readyz", nil))
+	return w.Code
+}
+
+func TestGatewayRegisterRetry(t *testing.T) {
+	reg := &fakeRegister{fails: 1, keys: make(map[string]string)}
+	key := "gateway/demo"
+	s, h := newRegServer(t, reg, &key)
+
+	if err := s.regGateway(); err == nil {
+		t.Fatal("register failure not returned")
+	}
+	if code := readyz(h); code != http.StatusServiceUnavailable {
+		t.Fatal("ready before the gateway registered", code)
+	}
+	if err := s.regGateway(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reg.keys[key]; !ok {
+		t.Fatal("gateway not registered on retry")
+	}
+	if code := readyz(h); code != http.StatusOK {
+		t.Fatal("not ready after the gateway registered", code)
+	}
+
+	key = ""
+	if err := s.refreshGateway(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reg.keys) != 0 {
+		t.Fatal("old gateway key not unregistered")
+	}
+	if code := readyz(h); code != http.StatusOK {
+		t.Fatal("readiness waits for an empty gateway key", code)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/internal/reqinfo"
 	"github.com/obnahsgnaw/api/pkg/apierr"
 	"github.com/obnahsgnaw/api/pkg/errobj"
 	"github.com/obnahsgnaw/application/pkg/debug"
@@ -18,6 +19,14 @@ import (
 	"strings"
 )
 
+// cachingHeaders the outgoing metadata mapped to the http caching headers
+var cachingHeaders = map[string]string{
+	"cache-control": "Cache-Control",
+	"last-modified": "Last-Modified",
+	"expires":       "Expires",
+	"etag":          "ETag",
+}
+
 func OutgoingHeaderMatcher(key string) (string, bool) {
 	if key == "StatusCode" {
 		return key, true
@@ -25,6 +34,9 @@ func OutgoingHeaderMatcher(key string) (string, bool) {
 	if strings.HasPrefix(key, "x-") {
 		return key, true
 	}
+	if h, ok := cachingHeaders[key]; ok {
+		return h, true
+	}
 	return fmt.Sprintf("%s%s", runtime.MetadataHeaderPrefix, key), true
 }
 
@@ -97,6 +109,12 @@ func HandlerErr(err error, marshaler runtime.Marshaler, w http.ResponseWriter, e
 func HTTPErrorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error, p errobj.Provider, debugger debug.Debugger) {
 	w.Header().Del("Trailer")
 	w.Header().Del("Transfer-Encoding")
+	if IsDeadlineExceeded(err) {
+		err = apierr.ToStatusError(apierr.NewGatewayTimeoutError(apierr.RequestTimeout, err).WithRequestTypeAndId(r.Header.Get("X-Request-Type"), r.Header.Get("X-Request-ID")))
+	}
+	if info, ok := reqinfo.From(ctx); ok {
+		info.SetErrCode(ErrCode(err))
+	}
 	var doForwardTrailers bool
 	var md runtime.ServerMetadata
 	HandlerErr(err, marshaler, w, func() {
@@ -126,6 +144,45 @@ func HTTPErrorHandler(ctx context.Context, mux *runtime.ServeMux, marshaler runt
 	}
 }
 
+// IsDeadlineExceeded return if the error is caused by the request deadline
+func IsDeadlineExceeded(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var apiErr *apierr.ApiError
+	if errors.As(err, &apiErr) {
+		return false
+	}
+	return status.Code(err) == codes.DeadlineExceeded
+}
+
+// ErrCode return the error code HandlerErr responds with
+func ErrCode(err error) uint32 {
+	var customStatus *runtime.HTTPStatusError
+	if errors.As(err, &customStatus) {
+		err = customStatus.Err
+	}
+	var apiErr *apierr.ApiError
+	if !errors.As(err, &apiErr) {
+		return apierr.InternalError.Code()
+	}
+	return apiErr.ErrCode.Code()
+}
+
+// RecordErrCode record the error code to the request info of the request id carried by the api error
+func RecordErrCode(err error) {
+	var customStatus *runtime.HTTPStatusError
+	if errors.As(err, &customStatus) {
+		err = customStatus.Err
+	}
+	var apiErr *apierr.ApiError
+	if errors.As(err, &apiErr) && apiErr.RqId != "" {
+		if info, ok := reqinfo.Lookup(apiErr.RqId); ok {
+			info.SetErrCode(apiErr.ErrCode.Code())
+		}
+	}
+}
+
 func CommonErrorResponse(pb *spb.Status) errobj.Param {
 	return errobj.Param{
 		Code:    uint32(pb.GetCode()),
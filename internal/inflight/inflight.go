@@ -0,0 +1,73 @@
+package inflight
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Counter in-flight request counter, closed counter refuse new requests
+type Counter struct {
+	count  int64
+	closed int32
+}
+
+func New() *Counter {
+	return &Counter{}
+}
+
+// Acquire count a new request, return false when closed
+func (c *Counter) Acquire() bool {
+	if c.Closed() {
+		return false
+	}
+	atomic.AddInt64(&c.count, 1)
+	if c.Closed() {
+		c.Release()
+		return false
+	}
+	return true
+}
+
+// Release a finished request
+func (c *Counter) Release() {
+	atomic.AddInt64(&c.count, -1)
+}
+
+// Count return the in-flight request count
+func (c *Counter) Count() int64 {
+	return atomic.LoadInt64(&c.count)
+}
+
+// Close stop accepting new requests
+func (c *Counter) Close() {
+	atomic.StoreInt32(&c.closed, 1)
+}
+
+// Open accept new requests again
+func (c *Counter) Open() {
+	atomic.StoreInt32(&c.closed, 0)
+}
+
+func (c *Counter) Closed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+// Wait for the in-flight requests done, report the count every interval, return false when timeout
+func (c *Counter) Wait(timeout, interval time.Duration, report func(count int64)) bool {
+	deadline := time.Now().Add(timeout)
+	reported := time.Now()
+	for {
+		n := c.Count()
+		if n <= 0 {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		if report != nil && time.Since(reported) >= interval {
+			report(n)
+			reported = time.Now()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
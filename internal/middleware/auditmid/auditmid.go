@@ -0,0 +1,74 @@
+package auditmid
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/obnahsgnaw/api/internal/reqinfo"
+	"github.com/obnahsgnaw/api/service/audit"
+	"io"
+	"time"
+)
+
+// NewAuditMid 需要最先执行以审计被拒绝的请求；记录在请求信息结束回调中输出，回调注册在后续中间件之后，先于认证中间件移除用户执行
+func NewAuditMid(manager *audit.Manager, debugCb func(msg string)) gin.HandlerFunc {
+	if debugCb == nil {
+		debugCb = func(msg string) {}
+	}
+	return func(c *gin.Context) {
+		info, ok := reqinfo.From(c.Request.Context())
+		if !ok {
+			c.Next()
+			return
+		}
+		var body *countReader
+		if c.Request.Body != nil {
+			body = &countReader{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
+		r := c.Request
+		rec := audit.Record{
+			Time:     info.Start(),
+			RqId:     info.RqId(),
+			ClientIp: c.ClientIP(),
+			Method:   r.Method,
+			Path:     r.URL.Path,
+		}
+		defer info.OnFinish(func(i *reqinfo.Info) {
+			rec.Pattern = i.Pattern()
+			if !manager.Audited(rec.Method, rec.Pattern, rec.Path) {
+				return
+			}
+			rec.AppId = r.Header.Get(manager.AppIdHeaderKey())
+			if users := manager.Users(); users != nil {
+				if user, ok := users.Get(rec.RqId); ok {
+					rec.UserId = user.Id()
+					rec.Uid = user.Uid()
+				}
+			}
+			rec.RpcMethod = i.RpcMethod()
+			rec.Status = i.Status()
+			rec.ErrCode, _ = i.ErrCode()
+			rec.Latency = time.Since(rec.Time)
+			rec.BytesOut = int64(i.Size())
+			if body != nil {
+				rec.BytesIn = body.n
+			}
+			if rec.BytesIn < r.ContentLength {
+				rec.BytesIn = r.ContentLength
+			}
+			manager.Sink().Write(rec)
+		})
+		c.Next()
+	}
+}
+
+// countReader count the request body bytes read
+type countReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/obnahsgnaw/api/internal/marshaler"
 	"github.com/obnahsgnaw/api/pkg/apierr"
 	"github.com/obnahsgnaw/api/service/authedapp"
+	"github.com/obnahsgnaw/api/service/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"net/http"
 )
 
@@ -25,7 +27,10 @@ func NewAppMid(manager *authedapp.Manager, debugCb func(msg string), errHandle f
 		appId := c.Request.Header.Get(manager.AppidHeaderKey())
 
 		if ig, igApp := manager.Ignored(c); !ig {
-			if app, err = manager.Provider().GetValidApp(rqId, appId, manager.Project, true); err != nil {
+			_, span := tracing.Start(c.Request.Context(), "app.validate", attribute.String("app_id", appId))
+			app, err = manager.Provider().GetValidApp(rqId, appId, manager.Project, true)
+			tracing.End(span, err)
+			if err != nil {
 				debugCb(logPrefix + "validate failed,err=" + err.Error())
 				c.Abort()
 				errHandle(
@@ -4,8 +4,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/obnahsgnaw/api/internal/marshaler"
+	"github.com/obnahsgnaw/api/internal/reqinfo"
 	"github.com/obnahsgnaw/api/pkg/apierr"
 	"github.com/obnahsgnaw/api/service/autheduser"
+	"github.com/obnahsgnaw/api/service/tracing"
 	"net/http"
 	"strconv"
 )
@@ -26,7 +28,10 @@ func NewAuthMid(manager *autheduser.Manager, debugCb func(msg string), errHandle
 
 		if !manager.Ignored(c) {
 			if token != "" {
-				if user, err = manager.Provider().GetTokenUser(rqId, appId, token); err != nil {
+				_, span := tracing.Start(c.Request.Context(), "auth.validate")
+				user, err = manager.Provider().GetTokenUser(rqId, appId, token)
+				tracing.End(span, err)
+				if err != nil {
 					debugCb(logPrefix + "validate failed, err=" + err.Error())
 					c.Abort()
 					errHandle(
@@ -39,6 +44,9 @@ func NewAuthMid(manager *autheduser.Manager, debugCb func(msg string), errHandle
 
 				debugCb(logPrefix + "accessed, user=" + strconv.Itoa(int(user.Id())))
 				c.Request.Header.Set(manager.UserIdHeaderKey(), user.Uid())
+				if info, ok := reqinfo.From(c.Request.Context()); ok {
+					info.SetUserId(user.Uid())
+				}
 
 				manager.Add(rqId, user)
 			} else {
@@ -48,6 +56,14 @@ func NewAuthMid(manager *autheduser.Manager, debugCb func(msg string), errHandle
 			debugCb(logPrefix + "validate ignored by ignorer")
 		}
 
+		// keep the user until the request finished for the finishers such as the audit
+		if info, ok := reqinfo.From(c.Request.Context()); ok {
+			info.OnFinish(func(*reqinfo.Info) {
+				manager.Rm(rqId)
+			})
+			c.Next()
+			return
+		}
 		c.Next()
 		manager.Rm(rqId)
 	}
@@ -7,6 +7,7 @@ import (
 	"github.com/obnahsgnaw/api/internal/marshaler"
 	"github.com/obnahsgnaw/api/pkg/apierr"
 	"github.com/obnahsgnaw/api/service/crypt"
+	"github.com/obnahsgnaw/api/service/tracing"
 	"io"
 	"net/http"
 )
@@ -16,11 +17,16 @@ type BodyWriter struct {
 	body *bytes.Buffer
 }
 
+// Write buffer the response body, it is encrypted and written after the handlers
 func (w BodyWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
-	return w.ResponseWriter.Write(b)
+	return w.body.Write(b)
 }
 
+func (w BodyWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// NewCryptMid crypt middleware, decrypt the request body and write the response body encrypted, the empty body as is
 func NewCryptMid(manager *crypt.Manager, debugCb func(msg string), errHandle func(err error, marshaler runtime.Marshaler, w http.ResponseWriter)) gin.HandlerFunc {
 	if debugCb == nil {
 		debugCb = func(msg string) {}
@@ -32,10 +38,15 @@ func NewCryptMid(manager *crypt.Manager, debugCb func(msg string), errHandle fun
 		appId := c.GetHeader(manager.AppIdHeaderKey())
 		userId := c.GetHeader(manager.UserIdHeaderKey())
 		iv := c.GetHeader(manager.UserIvHeaderKey())
-		body, _ := io.ReadAll(c.Request.Body)
+		body, err := io.ReadAll(c.Request.Body)
 		debugCb(logPrefix + "body in=" + string(body))
 		// 解密
-		decrypted, err := manager.Provider().Decrypt(appId, userId, []byte(iv), body)
+		var decrypted []byte
+		if err == nil {
+			_, span := tracing.Start(c.Request.Context(), "crypt.decrypt")
+			decrypted, err = manager.Provider().Decrypt(appId, userId, []byte(iv), body)
+			tracing.End(span, err)
+		}
 		if err != nil {
 			debugCb(logPrefix + "decrypt failed, err=" + err.Error())
 			c.Abort()
@@ -54,10 +65,15 @@ func NewCryptMid(manager *crypt.Manager, debugCb func(msg string), errHandle fun
 		}
 		c.Writer = bdWriter
 		c.Next()
+		c.Writer = bdWriter.ResponseWriter
+		if bdWriter.body.Len() == 0 {
+			return
+		}
 		// 加密
+		_, span := tracing.Start(c.Request.Context(), "crypt.encrypt")
 		encrypted, err := manager.Provider().Encrypt(appId, userId, []byte(iv), bdWriter.body.Bytes())
+		tracing.End(span, err)
 		if err != nil {
-			bdWriter.body = bytes.NewBufferString("")
 			debugCb(logPrefix + "encrypt failed, err=" + err.Error())
 			c.Abort()
 			errHandle(
@@ -67,7 +83,8 @@ func NewCryptMid(manager *crypt.Manager, debugCb func(msg string), errHandle fun
 			)
 			return
 		}
-		bdWriter.body = bytes.NewBuffer(encrypted)
-		debugCb(logPrefix + "body out=" + bdWriter.body.String())
+		debugCb(logPrefix + "body out=" + string(encrypted))
+		c.Writer.Header().Del("Content-Length")
+		_, _ = c.Writer.Write(encrypted)
 	}
 }
@@ -2,11 +2,18 @@ package authmid
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/obnahsgnaw/api/internal/reqinfo"
+	"github.com/obnahsgnaw/api/service/tracing"
 	"github.com/obnahsgnaw/application/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"net/http"
 	"strings"
 )
 
-func NewRqIdMid() gin.HandlerFunc {
+// NewRqIdMid set the request id, and start the server span continuing the W3C trace context of the request if tracer not nil
+func NewRqIdMid(tracer *tracing.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		rqId := c.Request.Header.Get("X-Request-Id")
 		if rqId == "" || len(rqId) != 35 || !strings.HasPrefix(rqId, "rq_") {
@@ -16,6 +23,39 @@ func NewRqIdMid() gin.HandlerFunc {
 		c.Request.Header.Set("X-Request-Type", "http")
 		c.Request.Header.Set("X-Request-From", "client")
 		c.Header("X-Request-Id", rqId)
+		if tracer == nil {
+			c.Next()
+			return
+		}
+
+		method := c.Request.Method
+		ctx := tracer.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracer.StartServer(ctx, method, semconv.HTTPMethod(method), semconv.HTTPTarget(c.Request.URL.Path),
+			semconv.HTTPClientIP(c.ClientIP()), attribute.String("rq_id", rqId))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
 		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPStatusCode(status))
+		if info, ok := reqinfo.From(c.Request.Context()); ok {
+			// the gin route for the requests rejected before routed by the mux
+			if pattern := info.Pattern(); pattern != "" {
+				span.SetName(method + " " + pattern)
+				span.SetAttributes(semconv.HTTPRoute(pattern))
+			} else if fullPath := c.FullPath(); fullPath != "" {
+				span.SetName(method + " " + fullPath)
+			}
+			if m := info.RpcMethod(); m != "" {
+				span.SetAttributes(semconv.RPCMethod(m))
+			}
+			if code, ok1 := info.ErrCode(); ok1 {
+				span.SetAttributes(attribute.Int64("err_code", int64(code)))
+			}
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/obnahsgnaw/api/internal/marshaler"
 	"github.com/obnahsgnaw/api/pkg/apierr"
 	"github.com/obnahsgnaw/api/service/sign"
+	"github.com/obnahsgnaw/api/service/tracing"
 	"net/http"
 	"strings"
 )
@@ -41,7 +42,10 @@ func NewSignMid(manager *sign.Manager, debugCb func(msg string), errHandle func(
 			return
 		}
 		// sign check
-		if err = manager.Provider().Validate(appId, userId, method, uri, s, t, n); err != nil {
+		_, span := tracing.Start(c.Request.Context(), "sign.validate")
+		err = manager.Provider().Validate(appId, userId, method, uri, s, t, n)
+		tracing.End(span, err)
+		if err != nil {
 			debugCb(logPrefix + "validate failed, err=" + err.Error())
 			c.Abort()
 			errHandle(
@@ -53,7 +57,10 @@ func NewSignMid(manager *sign.Manager, debugCb func(msg string), errHandle func(
 		}
 		c.Next()
 		// sign generate
-		if s1, t1, n1, err1 := manager.Provider().Generate(appId, userId, method, uri); err1 != nil {
+		_, span = tracing.Start(c.Request.Context(), "sign.generate")
+		s1, t1, n1, err1 := manager.Provider().Generate(appId, userId, method, uri)
+		tracing.End(span, err1)
+		if err1 != nil {
 			debugCb(logPrefix + "gen failed, err=" + err1.Error())
 			c.Abort()
 			errHandle(
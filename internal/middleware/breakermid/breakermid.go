@@ -0,0 +1,64 @@
+package breakermid
+
+import (
+	"errors"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/internal/marshaler"
+	"github.com/obnahsgnaw/api/internal/reqinfo"
+	"github.com/obnahsgnaw/api/internal/server"
+	"github.com/obnahsgnaw/api/pkg/apierr"
+	"github.com/obnahsgnaw/api/service"
+	"github.com/obnahsgnaw/api/service/breaker"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// NewMuxBreakerMid 需要pattern确定熔断器，所以放到mux中间件中；调用结果在响应写出后由请求信息回调判定
+func NewMuxBreakerMid(manager *breaker.Manager, debugCb func(msg string), errHandle func(err error, marshaler runtime.Marshaler, w http.ResponseWriter)) service.MuxRouteHandleFunc {
+	if debugCb == nil {
+		debugCb = func(msg string) {}
+	}
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string, pattern string) bool {
+		rqId := r.Header.Get("X-Request-ID")
+		rqType := r.Header.Get("X-Request-Type")
+		logPrefix := "breaker-middleware[" + rqType + "." + rqId + "]: "
+		pattern = server.NormalizePattern(pattern)
+		if manager.Ignored(r.Method, pattern) {
+			debugCb(logPrefix + "ignored by ignorer")
+			return true
+		}
+		info, ok := reqinfo.From(r.Context())
+		if !ok {
+			debugCb(logPrefix + "request info missing, ignored")
+			return true
+		}
+
+		key := manager.Key(r, pattern)
+		release, err := manager.Acquire(key)
+		if err != nil {
+			debugCb(logPrefix + "rejected, err=" + err.Error())
+			code := apierr.ServerBusy
+			var rejectErr *breaker.RejectError
+			if errors.As(err, &rejectErr) && rejectErr.Open {
+				code = apierr.CircuitOpen
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rejectErr.RetryAfter.Seconds()))))
+			}
+			errHandle(
+				apierr.ToStatusError(apierr.NewServiceUnavailableError(code, err).WithRequestTypeAndId(rqType, rqId)),
+				marshaler.GetMarshaler(r.Header.Get("Accept")),
+				w,
+			)
+			return false
+		}
+		info.OnFinish(func(i *reqinfo.Info) {
+			code, hasCode := i.ErrCode()
+			failed := manager.Failed(i.Status(), code, hasCode)
+			if failed {
+				debugCb(logPrefix + "call failed, key=" + key + ", status=" + strconv.Itoa(i.Status()))
+			}
+			release(failed)
+		})
+		return true
+	}
+}
@@ -0,0 +1,164 @@
+package cachemid
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/obnahsgnaw/api/internal/reqinfo"
+	"github.com/obnahsgnaw/api/service"
+	"github.com/obnahsgnaw/api/service/httpcache"
+	"net/http"
+)
+
+// entity headers removed from the 304 response
+var entityHeaders = []string{"Content-Type", "Content-Length", "Content-Encoding", "Content-Language", "Content-Range"}
+
+type keyCtx struct{}
+
+// NewCacheMid 需要在认证、加解密中间件之后执行，ETag按加密前的响应计算；流式响应不处理。服务端缓存的读取在权限校验之后，由 NewMuxCacheMid 执行
+func NewCacheMid(manager *httpcache.Manager, debugCb func(msg string)) gin.HandlerFunc {
+	if debugCb == nil {
+		debugCb = func(msg string) {}
+	}
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		rqId := c.Request.Header.Get("X-Request-ID")
+		rqType := c.Request.Header.Get("X-Request-Type")
+		logPrefix := "cache-middleware[" + rqType + "." + rqId + "]: "
+		if manager.Ignored(c) {
+			debugCb(logPrefix + "ignored by ignorer")
+			c.Next()
+			return
+		}
+
+		var key string
+		if manager.Cache() != nil {
+			var userId string
+			if info, ok := reqinfo.From(c.Request.Context()); ok {
+				userId = info.UserId()
+			}
+			var ok bool
+			if key, ok = manager.Key(c.Request, userId); ok && !manager.Bypass(c.Request) {
+				// looked up by the mux middleware after perm
+				c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), keyCtx{}, key))
+			}
+		}
+
+		before := c.Writer.Header().Clone()
+		w := &bufferWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.streaming {
+			return
+		}
+		status := w.Status()
+		body := w.body.Bytes()
+		if status != http.StatusOK {
+			write(c.Writer, c.Request, status, body)
+			return
+		}
+		h := c.Writer.Header()
+		if h.Get("ETag") == "" {
+			h.Set("ETag", manager.Etag(body))
+		}
+		if key != "" && h.Get("X-Cache") != "HIT" {
+			if ttl, ok := manager.Ttl(h); ok {
+				// the headers set by the outer middlewares are set again when hit
+				entry := httpcache.Entry{Header: make(http.Header), Body: append([]byte(nil), body...)}
+				for k, v := range h {
+					if _, ok := before[k]; !ok && k != "Content-Length" && k != "Date" {
+						entry.Header[k] = append([]string(nil), v...)
+					}
+				}
+				if err := manager.Cache().Set(c.Request.Context(), key, entry, ttl); err != nil {
+					debugCb(logPrefix + "cache set failed, err=" + err.Error())
+				}
+			}
+			h.Set("X-Cache", "MISS")
+		}
+		write(c.Writer, c.Request, status, body)
+	}
+}
+
+// NewMuxCacheMid 在权限、限流中间件之后读取服务端缓存，命中时直接写出缓存的响应，由 NewCacheMid 处理 304；缓存key由 NewCacheMid 设置
+func NewMuxCacheMid(manager *httpcache.Manager, debugCb func(msg string)) service.MuxRouteHandleFunc {
+	if debugCb == nil {
+		debugCb = func(msg string) {}
+	}
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string, pattern string) bool {
+		key, ok := r.Context().Value(keyCtx{}).(string)
+		if !ok || manager.Cache() == nil {
+			return true
+		}
+		rqId := r.Header.Get("X-Request-ID")
+		rqType := r.Header.Get("X-Request-Type")
+		logPrefix := "cache-middleware[" + rqType + "." + rqId + "]: "
+		entry, err := manager.Cache().Get(r.Context(), key)
+		if err != nil {
+			debugCb(logPrefix + "cache get failed, err=" + err.Error())
+			return true
+		}
+		if entry == nil {
+			return true
+		}
+		debugCb(logPrefix + "cache hit")
+		h := w.Header()
+		for k, v := range entry.Header {
+			h[k] = v
+		}
+		h.Set("X-Cache", "HIT")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(entry.Body)
+		return false
+	}
+}
+
+// write the body, or 304 if not modified
+func write(w gin.ResponseWriter, r *http.Request, status int, body []byte) {
+	h := w.Header()
+	if status == http.StatusOK && httpcache.NotModified(r, h) {
+		for _, k := range entityHeaders {
+			h.Del(k)
+		}
+		w.WriteHeader(http.StatusNotModified)
+		w.WriteHeaderNow()
+		return
+	}
+	w.WriteHeader(status)
+	if len(body) > 0 {
+		_, _ = w.Write(body)
+	}
+}
+
+// bufferWriter buffer the response, turn into pass-through once flushed
+type bufferWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	streaming bool
+}
+
+func (w *bufferWriter) Write(data []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *bufferWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		if w.body.Len() > 0 {
+			_, _ = w.ResponseWriter.Write(w.body.Bytes())
+			w.body.Reset()
+		}
+	}
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,266 @@
+package compressmid
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/internal/marshaler"
+	"github.com/obnahsgnaw/api/pkg/apierr"
+	"github.com/obnahsgnaw/api/service/compress"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// NewCompressMid 需要在加解密中间件之前执行：请求体先解压再解密，响应先加密再压缩
+func NewCompressMid(cnf *compress.Config, debugCb func(msg string), errHandle func(err error, marshaler runtime.Marshaler, w http.ResponseWriter)) gin.HandlerFunc {
+	if debugCb == nil {
+		debugCb = func(msg string) {}
+	}
+	encoders := newEncoderPools(cnf)
+	var readers sync.Pool
+	return func(c *gin.Context) {
+		rqId := c.Request.Header.Get("X-Request-ID")
+		rqType := c.Request.Header.Get("X-Request-Type")
+		logPrefix := "compress-middleware[" + rqType + "." + rqId + "]: "
+
+		if strings.EqualFold(strings.TrimSpace(c.Request.Header.Get("Content-Encoding")), compress.Gzip) {
+			zr, err := gzipReader(&readers, c.Request.Body)
+			if err != nil {
+				debugCb(logPrefix + "decompress failed, err=" + err.Error())
+				c.Abort()
+				errHandle(
+					apierr.ToStatusError(apierr.NewBadRequestError(apierr.DecompressFailed, err).WithRequestTypeAndId(rqType, rqId)),
+					marshaler.GetMarshaler(c.GetHeader("Accept")),
+					c.Writer,
+				)
+				return
+			}
+			c.Request.Body = &bodyReader{zr: zr, body: c.Request.Body, limit: cnf.MaxRequest()}
+			c.Request.Header.Del("Content-Encoding")
+			c.Request.Header.Del("Content-Length")
+			c.Request.ContentLength = -1
+			defer readers.Put(zr)
+		}
+
+		if c.Request.Method == http.MethodHead || c.Request.Header.Get("Upgrade") != "" {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := cnf.Negotiate(c.Request.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		pool, ok := encoders[encoding]
+		if !ok {
+			c.Next()
+			return
+		}
+		w := &compressWriter{ResponseWriter: c.Writer, cnf: cnf, encoding: encoding, pool: pool}
+		c.Writer = w
+		defer func() {
+			if err := w.close(); err != nil {
+				debugCb(logPrefix + "compress failed, err=" + err.Error())
+			}
+		}()
+		c.Next()
+	}
+}
+
+func newEncoderPools(cnf *compress.Config) map[string]*sync.Pool {
+	pools := make(map[string]*sync.Pool)
+	for _, e := range cnf.Supported() {
+		var fn func() interface{}
+		switch e {
+		case compress.Gzip:
+			level := gzip.DefaultCompression
+			if cnf.Level != 0 {
+				level = cnf.Level
+			}
+			fn = func() interface{} {
+				w, err := gzip.NewWriterLevel(io.Discard, level)
+				if err != nil {
+					w = gzip.NewWriter(io.Discard)
+				}
+				return w
+			}
+		case compress.Deflate:
+			level := flate.DefaultCompression
+			if cnf.Level != 0 {
+				level = cnf.Level
+			}
+			fn = func() interface{} {
+				w, err := flate.NewWriter(io.Discard, level)
+				if err != nil {
+					w, _ = flate.NewWriter(io.Discard, flate.DefaultCompression)
+				}
+				return w
+			}
+		case compress.Brotli:
+			level := brotli.DefaultCompression
+			if cnf.Level != 0 {
+				level = cnf.Level
+			}
+			fn = func() interface{} {
+				return brotli.NewWriterLevel(io.Discard, level)
+			}
+		default:
+			continue
+		}
+		pools[e] = &sync.Pool{New: fn}
+	}
+	return pools
+}
+
+func gzipReader(pool *sync.Pool, body io.Reader) (*gzip.Reader, error) {
+	if zr, ok := pool.Get().(*gzip.Reader); ok {
+		if err := zr.Reset(body); err != nil {
+			pool.Put(zr)
+			return nil, err
+		}
+		return zr, nil
+	}
+	return gzip.NewReader(body)
+}
+
+var errBodyTooLarge = errors.New("decompressed request body too large")
+
+// bodyReader the decompressed request body, limited to the max size
+type bodyReader struct {
+	zr    *gzip.Reader
+	body  io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (r *bodyReader) Read(p []byte) (int, error) {
+	if r.limit > 0 && r.read >= r.limit {
+		// probe one more byte to tell the exact size from a larger one
+		var b [1]byte
+		if n, _ := r.zr.Read(b[:]); n > 0 {
+			return 0, errBodyTooLarge
+		}
+		return 0, io.EOF
+	}
+	if r.limit > 0 && int64(len(p)) > r.limit-r.read {
+		p = p[:r.limit-r.read]
+	}
+	n, err := r.zr.Read(p)
+	r.read += int64(n)
+	return n, err
+}
+
+func (r *bodyReader) Close() error {
+	return r.body.Close()
+}
+
+// compressWriter buffer the response until the min size reached, then compress the rest with the encoder
+type compressWriter struct {
+	gin.ResponseWriter
+	cnf      *compress.Config
+	encoding string
+	pool     *sync.Pool
+	buf      []byte
+	enc      encoder
+	decided  bool
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, data...)
+		if len(w.buf) < w.cnf.Min() {
+			return len(data), nil
+		}
+		w.decide(true)
+		if err := w.flushBuf(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+		_ = w.flushBuf()
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(len(w.buf) > 0)
+		_ = w.flushBuf()
+	}
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide whether to compress, the headers are not written yet
+func (w *compressWriter) decide(compressed bool) {
+	w.decided = true
+	h := w.Header()
+	if !compressed || h.Get("Content-Encoding") != "" || w.cnf.Excluded(h.Get("Content-Type")) {
+		return
+	}
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+	w.enc = w.pool.Get().(encoder)
+	w.enc.Reset(w.ResponseWriter)
+}
+
+func (w *compressWriter) flushBuf() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *compressWriter) close() error {
+	if !w.decided {
+		w.decide(false)
+	}
+	err := w.flushBuf()
+	if w.enc != nil {
+		if err1 := w.enc.Close(); err == nil {
+			err = err1
+		}
+		w.enc.Reset(io.Discard)
+		w.pool.Put(w.enc)
+		w.enc = nil
+	}
+	return err
+}
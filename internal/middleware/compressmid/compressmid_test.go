@@ -0,0 +1,113 @@
+package compressmid
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/service/compress"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newEngine(cnf *compress.Config, h gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(NewCompressMid(cnf, nil, func(err error, m runtime.Marshaler, w http.ResponseWriter) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	e.Any("/", h)
+	return e
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestResponseNegotiation(t *testing.T) {
+	large := strings.Repeat("a", 2048)
+	e := newEngine(&compress.Config{}, func(c *gin.Context) {
+		if c.Query("type") != "" {
+			c.Header("Content-Type", c.Query("type"))
+		}
+		size, _ := strconv.Atoi(c.Query("size"))
+		c.String(http.StatusOK, large[:size])
+	})
+	do := func(query, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		if accept != "" {
+			r.Header.Set("Accept-Encoding", accept)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("size=2048", "gzip")
+	if w.Header().Get("Content-Encoding") != compress.Gzip || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatal("response not gzipped")
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != large {
+		t.Fatal("unexpected decompressed body")
+	}
+	if w = do("size=2048", "br, gzip"); w.Header().Get("Content-Encoding") != compress.Brotli {
+		t.Fatal("preferred encoding not negotiated")
+	}
+	if w = do("size=2048", ""); w.Header().Get("Content-Encoding") != "" || w.Body.String() != large {
+		t.Fatal("response compressed without Accept-Encoding")
+	}
+	if w = do("size=100", "gzip"); w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 100 {
+		t.Fatal("response under the min size compressed")
+	}
+	if w = do("size=2048&type=image/png", "gzip"); w.Header().Get("Content-Encoding") != "" {
+		t.Fatal("excluded type compressed")
+	}
+}
+
+func TestRequestDecompression(t *testing.T) {
+	e := newEngine(&compress.Config{MaxRequestSize: 16}, func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+	do := func(body []byte) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		r.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do(gzipped(t, []byte("hello"))); w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Fatal("request body not decompressed")
+	}
+	if w := do(gzipped(t, bytes.Repeat([]byte("a"), 16))); w.Code != http.StatusOK {
+		t.Fatal("request body of the max size rejected")
+	}
+	if w := do(gzipped(t, bytes.Repeat([]byte("a"), 17))); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatal("request body over the max size not rejected")
+	}
+	if w := do([]byte("not gzip")); w.Code != http.StatusBadRequest {
+		t.Fatal("invalid gzip body not rejected")
+	}
+}
@@ -0,0 +1,124 @@
+package corsmid
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/service/cors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// NewCorsMid 需要在app、auth等中间件之前执行，预检请求直接应答，错误响应也带上CORS头；网关写出的 Grpc-Metadata-* 头在响应写出时追加到暴露列表
+func NewCorsMid(cnf *cors.Config, expose []string, debugCb func(msg string)) gin.HandlerFunc {
+	if debugCb == nil {
+		debugCb = func(msg string) {}
+	}
+	exposes := cnf.Exposes(expose...)
+	return func(c *gin.Context) {
+		origin := c.Request.Header.Get("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		rqId := c.Request.Header.Get("X-Request-Id")
+		rqType := c.Request.Header.Get("X-Request-Type")
+		logPrefix := "cors-middleware[" + rqType + "." + rqId + "]: "
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+
+		if c.Request.Method == http.MethodOptions && c.Request.Header.Get("Access-Control-Request-Method") != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			allowed, wildcard := cnf.MatchStatic(origin)
+			if !allowed {
+				allowed, wildcard = cnf.MatchPreflightApp(c.Request, origin)
+			}
+			if allowed {
+				setOrigin(h, cnf, origin, wildcard)
+				h.Set("Access-Control-Allow-Methods", cnf.Methods())
+				h.Set("Access-Control-Allow-Headers", cnf.Headers())
+				if age := cnf.Age(); age != "" {
+					h.Set("Access-Control-Max-Age", age)
+				}
+				debugCb(logPrefix + "preflight accessed, origin=" + origin)
+			} else {
+				debugCb(logPrefix + "preflight rejected, origin=" + origin)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		allowed, wildcard := cnf.MatchStatic(origin)
+		if !allowed {
+			allowed, wildcard = cnf.MatchApp(c.Request, origin)
+		}
+		if !allowed {
+			debugCb(logPrefix + "origin not allowed, origin=" + origin)
+			c.Next()
+			return
+		}
+		setOrigin(h, cnf, origin, wildcard)
+		h.Set("Access-Control-Expose-Headers", strings.Join(exposes, ", "))
+		c.Writer = &exposeWriter{ResponseWriter: c.Writer, exposes: exposes}
+		c.Next()
+	}
+}
+
+func setOrigin(h http.Header, cnf *cors.Config, origin string, wildcard bool) {
+	if wildcard && !cnf.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if cnf.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// exposeWriter append the Grpc-Metadata-* headers to the exposed headers before the header written
+type exposeWriter struct {
+	gin.ResponseWriter
+	exposes []string
+}
+
+func (w *exposeWriter) expose() {
+	if w.Written() {
+		return
+	}
+	var metadata []string
+	for k := range w.Header() {
+		if strings.HasPrefix(k, runtime.MetadataHeaderPrefix) {
+			metadata = append(metadata, k)
+		}
+	}
+	if len(metadata) > 0 {
+		sort.Strings(metadata)
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(append(append([]string{}, w.exposes...), metadata...), ", "))
+	}
+}
+
+func (w *exposeWriter) WriteHeader(code int) {
+	w.expose()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *exposeWriter) WriteHeaderNow() {
+	w.expose()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *exposeWriter) Write(data []byte) (int, error) {
+	w.expose()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *exposeWriter) WriteString(s string) (int, error) {
+	w.expose()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *exposeWriter) Flush() {
+	w.expose()
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,70 @@
+package corsmid
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/obnahsgnaw/api/service/cors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newCorsEngine(cnf *cors.Config) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(NewCorsMid(cnf, nil, nil))
+	e.Any("/v1/hello", func(c *gin.Context) {
+		c.String(http.StatusOK, "hello")
+	})
+	return e
+}
+
+func preflight(e *gin.Engine, origin string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodOptions, "/v1/hello", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	return w
+}
+
+func TestPreflight(t *testing.T) {
+	e := newCorsEngine(&cors.Config{AllowOrigins: []string{"https://a.com", "https://*.b.com"}})
+
+	w := preflight(e, "https://evil.com")
+	if w.Code != http.StatusNoContent {
+		t.Fatal("preflight of an unknown origin passed to the handler", w.Code)
+	}
+	for _, k := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Methods", "Access-Control-Allow-Headers"} {
+		if w.Header().Get(k) != "" {
+			t.Fatal("cors header set for an unknown origin: " + k)
+		}
+	}
+
+	w = preflight(e, "https://x.b.com")
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://x.b.com" || w.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Fatal("preflight of a subdomain origin not allowed", w.Header())
+	}
+	if w = preflight(e, "https://b.com"); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("subdomain pattern matched the parent domain")
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	e := newCorsEngine(&cors.Config{AllowOrigins: []string{"*"}, AllowCredentials: true})
+	r := httptest.NewRequest(http.MethodGet, "/v1/hello", nil)
+	r.Header.Set("Origin", "https://a.com")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://a.com" || w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatal("credentialed wildcard origin not echoed", w.Header())
+	}
+
+	e = newCorsEngine(&cors.Config{AllowOrigins: []string{"https://a.com"}})
+	r = httptest.NewRequest(http.MethodGet, "/v1/hello", nil)
+	r.Header.Set("Origin", "https://evil.com")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("unknown origin allowed", w.Header())
+	}
+}
@@ -0,0 +1,152 @@
+package deadlinemid
+
+import (
+	"errors"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/internal/marshaler"
+	"github.com/obnahsgnaw/api/internal/server"
+	"github.com/obnahsgnaw/api/pkg/apierr"
+	"github.com/obnahsgnaw/api/service"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TimeoutHeaderKey     = "X-Request-Timeout"
+	GrpcTimeoutHeaderKey = "Grpc-Timeout"
+)
+
+// Config request timeouts, a pattern timeout overrides the default, a client timeout only shortens it, all capped by the max
+type Config struct {
+	Default  time.Duration
+	Max      time.Duration
+	patterns map[string]time.Duration // method pattern => timeout
+}
+
+func NewConfig() *Config {
+	return &Config{patterns: make(map[string]time.Duration)}
+}
+
+// SetPattern set the default timeout of the gateway pattern
+func (c *Config) SetPattern(method, pattern string, timeout time.Duration) {
+	c.patterns[strings.ToUpper(method)+" "+server.NormalizePattern(pattern)] = timeout
+}
+
+// Timeout return the timeout of the request, 0 means no timeout
+func (c *Config) Timeout(r *http.Request, pattern string) (time.Duration, error) {
+	timeout, ok := c.patterns[r.Method+" "+server.NormalizePattern(pattern)]
+	if !ok {
+		timeout = c.Default
+	}
+	var client time.Duration
+	if v := r.Header.Get(TimeoutHeaderKey); v != "" {
+		d, err := ParseTimeout(v)
+		if err != nil {
+			return 0, err
+		}
+		client = d
+	} else if v = r.Header.Get(GrpcTimeoutHeaderKey); v != "" {
+		d, err := ParseGrpcTimeout(v)
+		if err != nil {
+			return 0, err
+		}
+		client = d
+	}
+	// a client timeout only shortens the server one
+	if client > 0 && (timeout <= 0 || client < timeout) {
+		timeout = client
+	}
+	if c.Max > 0 && (timeout <= 0 || timeout > c.Max) {
+		timeout = c.Max
+	}
+	return timeout, nil
+}
+
+// NewMuxDeadlineMid 需要pattern确定默认超时，所以放到mux中间件中；超时通过 Grpc-Timeout 头交给网关设置到 grpc 调用的 context
+func NewMuxDeadlineMid(cnf *Config, debugCb func(msg string), errHandle func(err error, marshaler runtime.Marshaler, w http.ResponseWriter)) service.MuxRouteHandleFunc {
+	if debugCb == nil {
+		debugCb = func(msg string) {}
+	}
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string, pattern string) bool {
+		rqId := r.Header.Get("X-Request-ID")
+		rqType := r.Header.Get("X-Request-Type")
+		logPrefix := "deadline-middleware[" + rqType + "." + rqId + "]: "
+		timeout, err := cnf.Timeout(r, pattern)
+		if err != nil {
+			debugCb(logPrefix + "invalid timeout, err=" + err.Error())
+			errHandle(
+				apierr.ToStatusError(apierr.NewBadRequestError(apierr.ValidateFailed, err).WithRequestTypeAndId(rqType, rqId)),
+				marshaler.GetMarshaler(r.Header.Get("Accept")),
+				w,
+			)
+			return false
+		}
+		r.Header.Del(TimeoutHeaderKey)
+		if timeout > 0 {
+			r.Header.Set(GrpcTimeoutHeaderKey, EncodeGrpcTimeout(timeout))
+			debugCb(logPrefix + "timeout=" + timeout.String())
+		} else {
+			r.Header.Del(GrpcTimeoutHeaderKey)
+		}
+		return true
+	}
+}
+
+// ParseTimeout parse a duration such as 1.5s or 500ms, or a number of milliseconds
+func ParseTimeout(v string) (time.Duration, error) {
+	v = strings.TrimSpace(v)
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if ms < 0 {
+			return 0, errors.New("timeout is negative")
+		}
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, errors.New("timeout format error")
+	}
+	if d < 0 {
+		return 0, errors.New("timeout is negative")
+	}
+	return d, nil
+}
+
+// ParseGrpcTimeout parse the grpc timeout format: at most 8 digits followed by H, M, S, m, u or n
+func ParseGrpcTimeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, errors.New("grpc timeout format error")
+	}
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("grpc timeout format error")
+	}
+	var unit time.Duration
+	switch v[len(v)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, errors.New("grpc timeout unit error")
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// EncodeGrpcTimeout encode the timeout in the grpc timeout format, rounded up to milliseconds
+func EncodeGrpcTimeout(d time.Duration) string {
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	if ms <= 99999999 {
+		return strconv.FormatInt(int64(ms), 10) + "m"
+	}
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10) + "S"
+}
@@ -0,0 +1,91 @@
+package deadlinemid
+
+import (
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func timeoutOf(t *testing.T, c *Config, method, pattern string, header ...string) time.Duration {
+	t.Helper()
+	r := httptest.NewRequest(method, "/", nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	d, err := c.Timeout(r, pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestTimeoutShortening(t *testing.T) {
+	c := NewConfig()
+	c.Default = 5 * time.Second
+	c.Max = 10 * time.Second
+	c.SetPattern("post", "/v1/upload", 8*time.Second)
+
+	if d := timeoutOf(t, c, "GET", "/v1/users"); d != 5*time.Second {
+		t.Fatal("default not applied", d)
+	}
+	if d := timeoutOf(t, c, "POST", "/v1/upload"); d != 8*time.Second {
+		t.Fatal("pattern timeout not applied", d)
+	}
+	if d := timeoutOf(t, c, "GET", "/v1/users", TimeoutHeaderKey, "1500"); d != 1500*time.Millisecond {
+		t.Fatal("client timeout not shortened", d)
+	}
+	if d := timeoutOf(t, c, "GET", "/v1/users", TimeoutHeaderKey, "30s"); d != 5*time.Second {
+		t.Fatal("client timeout lengthened the default", d)
+	}
+	if d := timeoutOf(t, c, "GET", "/v1/users", GrpcTimeoutHeaderKey, "200m"); d != 200*time.Millisecond {
+		t.Fatal("grpc timeout not shortened", d)
+	}
+
+	c.Default = 0
+	if d := timeoutOf(t, c, "GET", "/v1/users", TimeoutHeaderKey, "1m"); d != 10*time.Second {
+		t.Fatal("client timeout not capped by the max", d)
+	}
+	c.Max = 0
+	if d := timeoutOf(t, c, "GET", "/v1/users"); d != 0 {
+		t.Fatal("unexpected timeout without config", d)
+	}
+}
+
+func TestDeadlineMid(t *testing.T) {
+	c := NewConfig()
+	c.Default = time.Second
+	var status int
+	mid := NewMuxDeadlineMid(c, nil, func(err error, _ runtime.Marshaler, w http.ResponseWriter) {
+		status = http.StatusBadRequest
+	})
+
+	r := httptest.NewRequest("GET", "/v1/users", nil)
+	r.Header.Set(TimeoutHeaderKey, "250ms")
+	if !mid(httptest.NewRecorder(), r, nil, "/v1/users") || r.Header.Get(GrpcTimeoutHeaderKey) != "250m" || r.Header.Get(TimeoutHeaderKey) != "" {
+		t.Fatal("timeout not passed in the grpc header", r.Header)
+	}
+
+	r = httptest.NewRequest("GET", "/v1/users", nil)
+	r.Header.Set(TimeoutHeaderKey, "-1")
+	if mid(httptest.NewRecorder(), r, nil, "/v1/users") || status != http.StatusBadRequest {
+		t.Fatal("invalid timeout not rejected")
+	}
+}
+
+func TestGrpcTimeoutFormat(t *testing.T) {
+	for v, want := range map[string]time.Duration{"1H": time.Hour, "2S": 2 * time.Second, "300u": 300 * time.Microsecond} {
+		if d, err := ParseGrpcTimeout(v); err != nil || d != want {
+			t.Fatal("parse "+v+" failed", d, err)
+		}
+	}
+	for _, v := range []string{"", "1", "123456789S", "1x", "-1S"} {
+		if _, err := ParseGrpcTimeout(v); err == nil {
+			t.Fatal("invalid grpc timeout accepted: " + v)
+		}
+	}
+	if v := EncodeGrpcTimeout(1500 * time.Microsecond); v != "2m" {
+		t.Fatal("not rounded up: " + v)
+	}
+}
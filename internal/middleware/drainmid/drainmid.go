@@ -0,0 +1,35 @@
+package drainmid
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/internal/inflight"
+	"github.com/obnahsgnaw/api/internal/marshaler"
+	"github.com/obnahsgnaw/api/pkg/apierr"
+	"net/http"
+)
+
+// NewDrainMid count the in-flight requests, refuse new requests when the server is draining
+func NewDrainMid(counter *inflight.Counter, debugCb func(msg string), errHandle func(err error, marshaler runtime.Marshaler, w http.ResponseWriter)) gin.HandlerFunc {
+	if debugCb == nil {
+		debugCb = func(msg string) {}
+	}
+	return func(c *gin.Context) {
+		if !counter.Acquire() {
+			rqId := c.Request.Header.Get("X-Request-ID")
+			rqType := c.Request.Header.Get("X-Request-Type")
+			debugCb("drain-middleware[" + rqType + "." + rqId + "]: server draining, refused")
+			c.Abort()
+			errHandle(
+				apierr.ToStatusError(apierr.NewServiceUnavailableError(apierr.ServerUnavailable, errors.New("server draining")).WithRequestTypeAndId(rqType, rqId)),
+				marshaler.GetMarshaler(c.GetHeader("Accept")),
+				c.Writer,
+			)
+			return
+		}
+		defer counter.Release()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,161 @@
+package idempotencymid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/internal/marshaler"
+	"github.com/obnahsgnaw/api/internal/reqinfo"
+	"github.com/obnahsgnaw/api/pkg/apierr"
+	"github.com/obnahsgnaw/api/service/idempotency"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxKeyLength = 255
+
+// NewIdempotencyMid 需要在认证、加解密中间件之后执行，保存解密后的请求指纹和加密前的响应；存储出错时放行
+func NewIdempotencyMid(manager *idempotency.Manager, debugCb func(msg string), errHandle func(err error, marshaler runtime.Marshaler, w http.ResponseWriter)) gin.HandlerFunc {
+	if debugCb == nil {
+		debugCb = func(msg string) {}
+	}
+	return func(c *gin.Context) {
+		key := c.Request.Header.Get(manager.KeyHeaderKey())
+		if key == "" || !manager.Applied(c.Request.Method) {
+			c.Next()
+			return
+		}
+		rqId := c.Request.Header.Get("X-Request-ID")
+		rqType := c.Request.Header.Get("X-Request-Type")
+		logPrefix := "idempotency-middleware[" + rqType + "." + rqId + "]: "
+		if manager.Ignored(c) {
+			debugCb(logPrefix + "ignored by ignorer")
+			c.Next()
+			return
+		}
+		fail := func(e *apierr.ApiError) {
+			c.Abort()
+			errHandle(
+				apierr.ToStatusError(e.WithRequestTypeAndId(rqType, rqId)),
+				marshaler.GetMarshaler(c.GetHeader("Accept")),
+				c.Writer,
+			)
+		}
+		if len(key) > maxKeyLength {
+			debugCb(logPrefix + "key too long")
+			fail(apierr.NewBadRequestError(apierr.ValidateFailed, errors.New("idempotency key longer than "+strconv.Itoa(maxKeyLength))))
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			debugCb(logPrefix + "read body failed, err=" + err.Error())
+			fail(apierr.NewBadRequestError(apierr.ValidateFailed, err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var userId string
+		if info, ok := reqinfo.From(c.Request.Context()); ok {
+			userId = info.UserId()
+		}
+		scope := manager.Scope(c.Request, userId, key)
+		fingerprint := manager.Fingerprint(c.Request, body)
+		existing, err := manager.Store().Acquire(c.Request.Context(), scope, fingerprint, manager.LockTtl())
+		if err != nil {
+			debugCb(logPrefix + "store failed, passed, err=" + err.Error())
+			c.Next()
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				debugCb(logPrefix + "key reused, key=" + key)
+				fail(apierr.NewCodeConflictError(apierr.IdempotencyReused, errors.New("idempotency key["+key+"] reused by a different request")))
+			case existing.Response == nil:
+				debugCb(logPrefix + "key in progress, key=" + key)
+				fail(apierr.NewCodeConflictError(apierr.IdempotencyBusy, errors.New("idempotency key["+key+"] in progress")))
+			default:
+				debugCb(logPrefix + "replayed, key=" + key)
+				replay(c, existing.Response)
+			}
+			return
+		}
+
+		before := c.Writer.Header().Clone()
+		w := &captureWriter{ResponseWriter: c.Writer, limit: manager.MaxBodySize()}
+		c.Writer = w
+		finished := false
+		defer func() {
+			// the context of the request may be canceled already
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			status := w.Status()
+			if !finished || !manager.Storable(status) || w.overflow {
+				debugCb(logPrefix + "released, key=" + key + ", status=" + strconv.Itoa(status))
+				if err := manager.Store().Release(ctx, scope); err != nil {
+					debugCb(logPrefix + "release failed, err=" + err.Error())
+				}
+				return
+			}
+			rec := idempotency.Record{Fingerprint: fingerprint, Response: &idempotency.Response{
+				Status: status,
+				Header: idempotency.StoredHeader(before, w.Header()),
+				Body:   w.body.Bytes(),
+			}}
+			if err := manager.Store().Save(ctx, scope, rec, manager.Ttl()); err != nil {
+				debugCb(logPrefix + "save failed, err=" + err.Error())
+			}
+		}()
+		c.Next()
+		finished = true
+	}
+}
+
+func replay(c *gin.Context, rp *idempotency.Response) {
+	c.Abort()
+	h := c.Writer.Header()
+	for k, v := range rp.Header {
+		h[k] = v
+	}
+	h.Set("Idempotent-Replayed", "true")
+	c.Writer.WriteHeader(rp.Status)
+	if len(rp.Body) > 0 {
+		_, _ = c.Writer.Write(rp.Body)
+	} else {
+		c.Writer.WriteHeaderNow()
+	}
+}
+
+// captureWriter copy the response body up to the limit
+type captureWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *captureWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+len(data) > w.limit {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
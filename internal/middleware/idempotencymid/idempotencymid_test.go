@@ -0,0 +1,109 @@
+package idempotencymid
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/internal/reqinfo"
+	"github.com/obnahsgnaw/api/pkg/apierr"
+	"github.com/obnahsgnaw/api/service/idempotency"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newIdempotencyEngine the handler counts the calls and responds the status of the query, the authed user is set as the auth middleware does
+func newIdempotencyEngine(calls *int) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(func(c *gin.Context) {
+		var info *reqinfo.Info
+		c.Request, info = reqinfo.Attach(c.Request, "", "")
+		info.SetUserId(c.GetHeader("Authed-User"))
+	})
+	e.Use(NewIdempotencyMid(idempotency.New(idempotency.NewMemoryStore()), nil, func(err error, _ runtime.Marshaler, w http.ResponseWriter) {
+		e := err.(*runtime.HTTPStatusError)
+		w.WriteHeader(e.HTTPStatus)
+		_, _ = w.Write([]byte(strconv.Itoa(int(e.Err.(*apierr.ApiError).ErrCode.RawCode()))))
+	}))
+	e.Any("/v1/orders", func(c *gin.Context) {
+		*calls++
+		status := http.StatusCreated
+		if v := c.Query("status"); v != "" {
+			status, _ = strconv.Atoi(v)
+		}
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(status, "order:"+strconv.Itoa(*calls)+":"+string(body))
+	})
+	return e
+}
+
+func send(e *gin.Engine, method, uri, key, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, uri, strings.NewReader(body))
+	r.Header.Set("Idempotency-Key", key)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	return w
+}
+
+func TestReplay(t *testing.T) {
+	var calls int
+	e := newIdempotencyEngine(&calls)
+	first := send(e, http.MethodPost, "/v1/orders", "k1", "a", "Authed-User", "u1")
+	if first.Code != http.StatusCreated {
+		t.Fatal("unexpected status", first.Code)
+	}
+	w := send(e, http.MethodPost, "/v1/orders", "k1", "a", "Authed-User", "u1")
+	if w.Code != http.StatusCreated || w.Body.String() != first.Body.String() || w.Header().Get("Idempotent-Replayed") != "true" || calls != 1 {
+		t.Fatal("response not replayed", w.Code, w.Body.String(), calls)
+	}
+
+	w = send(e, http.MethodPost, "/v1/orders", "k1", "b", "Authed-User", "u1")
+	if w.Code != http.StatusConflict || w.Body.String() != strconv.Itoa(int(apierr.IdempotencyReused.RawCode())) || calls != 1 {
+		t.Fatal("key reused by a different body not rejected", w.Code, w.Body.String())
+	}
+}
+
+func TestScope(t *testing.T) {
+	var calls int
+	e := newIdempotencyEngine(&calls)
+	send(e, http.MethodPost, "/v1/orders", "k1", "a", "Authed-User", "u1")
+	w := send(e, http.MethodPost, "/v1/orders", "k1", "a", "Authed-User", "u2", "X-User-Id", "u1")
+	if w.Header().Get("Idempotent-Replayed") != "" || calls != 2 {
+		t.Fatal("response of another user replayed by the user id header")
+	}
+	w = send(e, http.MethodPost, "/v1/orders", "k1", "a", "Authed-User", "u1", "X-App-Id", "app2")
+	if w.Header().Get("Idempotent-Replayed") != "" || calls != 3 {
+		t.Fatal("response of another app replayed")
+	}
+}
+
+func TestMethodsAndStatus(t *testing.T) {
+	var calls int
+	e := newIdempotencyEngine(&calls)
+	send(e, http.MethodGet, "/v1/orders", "k1", "", "Authed-User", "u1")
+	if w := send(e, http.MethodGet, "/v1/orders", "k1", "", "Authed-User", "u1"); w.Header().Get("Idempotent-Replayed") != "" || calls != 2 {
+		t.Fatal("GET checked")
+	}
+
+	for _, status := range []int{http.StatusInternalServerError, http.StatusTooManyRequests} {
+		calls = 0
+		key := "k" + strconv.Itoa(status)
+		send(e, http.MethodPost, "/v1/orders?status="+strconv.Itoa(status), key, "a", "Authed-User", "u1")
+		w := send(e, http.MethodPost, "/v1/orders?status="+strconv.Itoa(status), key, "a", "Authed-User", "u1")
+		if w.Code != status || w.Header().Get("Idempotent-Replayed") != "" || calls != 2 {
+			t.Fatal("response of status stored", status)
+		}
+	}
+
+	calls = 0
+	send(e, http.MethodPut, "/v1/orders?status=400", "k400", "a", "Authed-User", "u1")
+	if w := send(e, http.MethodPut, "/v1/orders?status=400", "k400", "a", "Authed-User", "u1"); w.Code != http.StatusBadRequest || w.Header().Get("Idempotent-Replayed") != "true" || calls != 1 {
+		t.Fatal("client error not replayed")
+	}
+}
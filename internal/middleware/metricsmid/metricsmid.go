@@ -0,0 +1,52 @@
+package metricsmid
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/obnahsgnaw/api/internal/reqinfo"
+	"github.com/obnahsgnaw/api/service/metrics"
+	"time"
+)
+
+// NewMetricsMid 需要最先执行以统计被拒绝的请求；网关请求在请求信息结束回调中统计，扩展路由请求没有请求信息，在后续中间件执行完后统计
+func NewMetricsMid(manager *metrics.Manager, debugCb func(msg string)) gin.HandlerFunc {
+	if debugCb == nil {
+		debugCb = func(msg string) {}
+	}
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		fullPath := c.FullPath()
+		info, ok := reqinfo.From(c.Request.Context())
+		if !ok {
+			manager.Start(metrics.KindExt)
+			start := time.Now()
+			defer func() {
+				manager.Done(metrics.Request{
+					Kind:    metrics.KindExt,
+					Method:  method,
+					Pattern: fullPath,
+					Status:  c.Writer.Status(),
+					Latency: time.Since(start),
+				})
+			}()
+			c.Next()
+			return
+		}
+		manager.Start(metrics.KindGateway)
+		defer info.OnFinish(func(i *reqinfo.Info) {
+			r := metrics.Request{
+				Kind:      metrics.KindGateway,
+				Method:    method,
+				Pattern:   i.Pattern(),
+				RpcMethod: i.RpcMethod(),
+				Status:    i.Status(),
+				Latency:   time.Since(i.Start()),
+			}
+			if r.Pattern == "" {
+				r.Pattern = fullPath
+			}
+			r.ErrCode, r.HasErr = i.ErrCode()
+			manager.Done(r)
+		})
+		c.Next()
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/obnahsgnaw/api/pkg/apierr"
 	"github.com/obnahsgnaw/api/service"
 	"github.com/obnahsgnaw/api/service/perm"
+	"github.com/obnahsgnaw/api/service/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"net/http"
 	"strings"
 )
@@ -26,7 +28,10 @@ func NewMuxPermissionMid(manager *perm.Manager, debugCb func(msg string), errHan
 		pattern = manager.PatternFormat(r, pattern)
 		// 验证权限
 		if !manager.Ignored(method, pattern) {
-			if err = manager.Provider().Can(rqId, appId, userId, method, pattern); err != nil {
+			_, span := tracing.Start(r.Context(), "perm.check", attribute.String("pattern", pattern))
+			err = manager.Provider().Can(rqId, appId, userId, method, pattern)
+			tracing.End(span, err)
+			if err != nil {
 				debugCb(logPrefix + "no perm, desc=" + err.Error())
 				errHandle(
 					apierr.ToStatusError(apierr.NewForbiddenError(apierr.PermMidNoPerm, err).WithRequestTypeAndId(rqType, rqId)),
@@ -0,0 +1,76 @@
+package ratelimitmid
+
+import (
+	"errors"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/internal/marshaler"
+	"github.com/obnahsgnaw/api/internal/reqinfo"
+	"github.com/obnahsgnaw/api/pkg/apierr"
+	"github.com/obnahsgnaw/api/service"
+	"github.com/obnahsgnaw/api/service/ratelimit"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NewMuxRateLimitMid 需要在认证中间件写入user id之后执行，所以放到mux中间件中；限流后端出错时放行
+func NewMuxRateLimitMid(manager *ratelimit.Manager, debugCb func(msg string), errHandle func(err error, marshaler runtime.Marshaler, w http.ResponseWriter)) service.MuxRouteHandleFunc {
+	if debugCb == nil {
+		debugCb = func(msg string) {}
+	}
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string, pattern string) bool {
+		rqId := r.Header.Get("X-Request-ID")
+		rqType := r.Header.Get("X-Request-Type")
+		logPrefix := "ratelimit-middleware[" + rqType + "." + rqId + "]: "
+		if manager.Ignored(r.Method, pattern) {
+			debugCb(logPrefix + "ignored by ignorer")
+			return true
+		}
+
+		var userId, clientIp string
+		if info, ok := reqinfo.From(r.Context()); ok {
+			userId, clientIp = info.UserId(), info.ClientIp()
+		}
+		var tightest *ratelimit.Result
+		for _, rule := range manager.Rules() {
+			key, ok := manager.Key(r, pattern, userId, clientIp, rule)
+			if !ok {
+				continue
+			}
+			rs, err := manager.Limiter().Allow(r.Context(), key, rule.Limit)
+			if err != nil {
+				debugCb(logPrefix + "limiter failed, passed, key=" + key + ", err=" + err.Error())
+				continue
+			}
+			if !rs.Allowed {
+				debugCb(logPrefix + "limited, key=" + key)
+				setHeaders(w, rs)
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(rs.RetryAfter)))
+				errHandle(
+					apierr.ToStatusError(apierr.NewTooManyRequestsError(apierr.RateLimited, errors.New("rate limited by "+rule.Name)).WithRequestTypeAndId(rqType, rqId)),
+					marshaler.GetMarshaler(r.Header.Get("Accept")),
+					w,
+				)
+				return false
+			}
+			if tightest == nil || rs.Remaining < tightest.Remaining {
+				tightest = &rs
+			}
+		}
+		if tightest != nil {
+			setHeaders(w, *tightest)
+		}
+		return true
+	}
+}
+
+func setHeaders(w http.ResponseWriter, rs ratelimit.Result) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rs.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(rs.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(rs.ResetAfter)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
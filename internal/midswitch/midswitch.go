@@ -0,0 +1,89 @@
+package midswitch
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/obnahsgnaw/api/service"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Switch runtime switch of a middleware, a disabled or ignored middleware is skipped
+type Switch struct {
+	mu      sync.RWMutex
+	enabled bool
+	ignores []string // path prefix, or "METHOD path-prefix"
+}
+
+func New() *Switch {
+	return &Switch{enabled: true}
+}
+
+func (s *Switch) Enabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.enabled
+}
+
+func (s *Switch) SetEnabled(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enabled = enabled
+}
+
+func (s *Switch) Ignores() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string{}, s.ignores...)
+}
+
+func (s *Switch) SetIgnores(ignores []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ignores = append([]string{}, ignores...)
+}
+
+// Skip return if the middleware should be skipped for the request method and the paths
+func (s *Switch) Skip(method string, paths ...string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.enabled {
+		return true
+	}
+	for _, rule := range s.ignores {
+		prefix := rule
+		if i := strings.Index(rule, " "); i > 0 {
+			if !strings.EqualFold(rule[:i], method) {
+				continue
+			}
+			prefix = strings.TrimSpace(rule[i+1:])
+		}
+		for _, p := range paths {
+			if strings.HasPrefix(p, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Gin wrap a gin middleware with the switch
+func Gin(s *Switch, h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.Skip(c.Request.Method, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		h(c)
+	}
+}
+
+// Mux wrap a mux middleware with the switch, ignore rules match the request path or the pattern
+func Mux(s *Switch, h service.MuxRouteHandleFunc) service.MuxRouteHandleFunc {
+	return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string, pattern string) bool {
+		if s.Skip(r.Method, r.URL.Path, pattern) {
+			return true
+		}
+		return h(w, r, pathParams, pattern)
+	}
+}
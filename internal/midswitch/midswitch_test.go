@@ -0,0 +1,24 @@
+package midswitch
+
+import "testing"
+
+func TestSkip(t *testing.T) {
+	s := New()
+	if s.Skip("GET", "/v1/hello") {
+		t.Fatal("enabled switch without ignores skipped")
+	}
+	s.SetIgnores([]string{"/v1/public", "POST /v1/upload"})
+	if !s.Skip("GET", "/v1/public/a") || !s.Skip("post", "/v1/upload") {
+		t.Fatal("ignored path not skipped")
+	}
+	if s.Skip("GET", "/v1/upload") {
+		t.Fatal("method rule matched another method")
+	}
+	if !s.Skip("GET", "/v1/1", "/v1/public/{id}") {
+		t.Fatal("pattern not matched")
+	}
+	s.SetEnabled(false)
+	if !s.Skip("GET", "/v1/hello") {
+		t.Fatal("disabled switch not skipped")
+	}
+}
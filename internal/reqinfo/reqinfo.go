@@ -0,0 +1,172 @@
+package reqinfo
+
+import (
+	"context"
+	"github.com/obnahsgnaw/application/pkg/utils"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type ctxKey struct{}
+
+// infos the unfinished infos by request id, for the error handlers without the request
+var (
+	infosMu sync.Mutex
+	infos   = make(map[string]*Info)
+)
+
+// Info the request info collected along the middlewares and the gateway, shared by the request context
+type Info struct {
+	mu        sync.RWMutex
+	rqId      string
+	clientIp  string
+	userId    string
+	start     time.Time
+	pattern   string
+	rpcMethod string
+	errCode   uint32
+	hasErr    bool
+	status    int
+	size      int
+	finishers []func(i *Info)
+}
+
+// Attach a new info to the request context, the client ip is resolved by the engine with its trusted proxies. The request id
+// is replaced by a generated one if it is in use by an unfinished request, it may be sent by the client
+func Attach(r *http.Request, rqId, clientIp string) (*http.Request, *Info) {
+	i := &Info{rqId: rqId, clientIp: clientIp, start: time.Now()}
+	if rqId != "" {
+		infosMu.Lock()
+		for {
+			if _, ok := infos[i.rqId]; !ok {
+				break
+			}
+			i.rqId = utils.GenLocalId("rq")
+		}
+		infos[i.rqId] = i
+		infosMu.Unlock()
+	}
+	return r.WithContext(context.WithValue(r.Context(), ctxKey{}, i)), i
+}
+
+// Lookup return the unfinished info of the request id
+func Lookup(rqId string) (*Info, bool) {
+	infosMu.Lock()
+	defer infosMu.Unlock()
+	i, ok := infos[rqId]
+	return i, ok
+}
+
+// From return the info of the request context
+func From(ctx context.Context) (*Info, bool) {
+	i, ok := ctx.Value(ctxKey{}).(*Info)
+	return i, ok
+}
+
+func (i *Info) RqId() string {
+	return i.rqId
+}
+
+func (i *Info) Start() time.Time {
+	return i.start
+}
+
+func (i *Info) ClientIp() string {
+	return i.clientIp
+}
+
+// UserId return the user id set by the auth middleware after the token validated, empty if not authenticated
+func (i *Info) UserId() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.userId
+}
+
+func (i *Info) SetUserId(userId string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.userId = userId
+}
+
+// Pattern return the normalized gateway pattern, empty if not routed by the mux
+func (i *Info) Pattern() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.pattern
+}
+
+func (i *Info) SetPattern(pattern string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.pattern = pattern
+}
+
+// RpcMethod return the rpc method, empty if not a gateway request
+func (i *Info) RpcMethod() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.rpcMethod
+}
+
+func (i *Info) SetRpcMethod(method string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rpcMethod = method
+}
+
+// ErrCode return the error code written by the gateway error handler
+func (i *Info) ErrCode() (uint32, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.errCode, i.hasErr
+}
+
+func (i *Info) SetErrCode(code uint32) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.errCode = code
+	i.hasErr = true
+}
+
+// Status return the response status, 0 before finished
+func (i *Info) Status() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.status
+}
+
+// Size return the response body size, 0 before finished
+func (i *Info) Size() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.size
+}
+
+// OnFinish add a finisher called after the response written, finishers run in reverse order
+func (i *Info) OnFinish(fn func(i *Info)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.finishers = append(i.finishers, fn)
+}
+
+// Finish set the response status and size, and run the finishers
+func (i *Info) Finish(status, size int) {
+	if size < 0 {
+		size = 0
+	}
+	infosMu.Lock()
+	if infos[i.rqId] == i {
+		delete(infos, i.rqId)
+	}
+	infosMu.Unlock()
+	i.mu.Lock()
+	i.status = status
+	i.size = size
+	finishers := i.finishers
+	i.finishers = nil
+	i.mu.Unlock()
+	for j := len(finishers) - 1; j >= 0; j-- {
+		finishers[j](i)
+	}
+}
@@ -0,0 +1,32 @@
+package reqinfo
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAttachDuplicateRqId(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	_, first := Attach(r, "rq_dup", "")
+	_, second := Attach(r, "rq_dup", "")
+	if first.RqId() != "rq_dup" || second.RqId() == "rq_dup" || second.RqId() == "" {
+		t.Fatal("duplicate request id not replaced: " + second.RqId())
+	}
+	if i, _ := Lookup("rq_dup"); i != first {
+		t.Fatal("request id taken over by the duplicate")
+	}
+	if i, _ := Lookup(second.RqId()); i != second {
+		t.Fatal("replaced request id not registered")
+	}
+
+	first.Finish(http.StatusOK, 0)
+	if _, ok := Lookup("rq_dup"); ok {
+		t.Fatal("finished info not removed")
+	}
+	_, third := Attach(r, second.RqId(), "")
+	second.Finish(http.StatusOK, 0)
+	if i, _ := Lookup(third.RqId()); i != third {
+		t.Fatal("info of another request removed")
+	}
+	third.Finish(http.StatusOK, 0)
+}
@@ -5,7 +5,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/obnahsgnaw/api/internal/middleware/authmid"
+	"github.com/obnahsgnaw/api/internal/reqinfo"
 	"github.com/obnahsgnaw/api/service"
+	"github.com/obnahsgnaw/api/service/tracing"
+	"net/http"
 	"strings"
 )
 
@@ -53,13 +56,13 @@ func (s StaticRoute) Match(c *gin.Context) {
 }
 
 // InitRpcHttpProxyServer 创建一个rpc服务的http代理服务
-func InitRpcHttpProxyServer(e *gin.Engine, mux *runtime.ServeMux, project string, version string, middlewares []gin.HandlerFunc, staticRoutes StaticRoute, withoutRoutePrefix bool) {
+func InitRpcHttpProxyServer(e *gin.Engine, mux *runtime.ServeMux, project string, version string, middlewares []gin.HandlerFunc, staticRoutes StaticRoute, withoutRoutePrefix bool, tracer *tracing.Manager) {
 	version = "/" + strings.Trim(version, "/")
 	prefix := version + "/" + project
 	if withoutRoutePrefix {
 		prefix = version
 	}
-	middlewares = append([]gin.HandlerFunc{authmid.NewRqIdMid(), replaceMid(prefix, version, staticRoutes, withoutRoutePrefix)}, middlewares...)
+	middlewares = append([]gin.HandlerFunc{authmid.NewRqIdMid(tracer), reqInfoMid(), replaceMid(prefix, version, staticRoutes, withoutRoutePrefix)}, middlewares...)
 	e.GET(prefix, append(append([]gin.HandlerFunc{}, middlewares...), gin.WrapH(mux))...)
 	e.Group(prefix+"/*gw", middlewares...).Any("", gin.WrapH(mux))
 }
@@ -69,6 +72,32 @@ func AddExtRoute(e *gin.Engine, routes []service.RouteProvider) {
 		rp(e)
 	}
 }
+
+// reqInfoMid attach the request info, and finish it after the response written
+func reqInfoMid() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var info *reqinfo.Info
+		clientIp, ok := c.Request.Context().Value(clientIpKey{}).(string)
+		if !ok {
+			clientIp = c.ClientIP()
+		}
+		rqId := c.Request.Header.Get("X-Request-Id")
+		c.Request, info = reqinfo.Attach(c.Request, rqId, clientIp)
+		if info.RqId() != rqId {
+			c.Request.Header.Set("X-Request-ID", info.RqId())
+			c.Header("X-Request-Id", info.RqId())
+		}
+		defer func() {
+			if rc := recover(); rc != nil {
+				info.Finish(http.StatusInternalServerError, c.Writer.Size())
+				panic(rc)
+			}
+			info.Finish(c.Writer.Status(), c.Writer.Size())
+		}()
+		c.Next()
+	}
+}
+
 func replaceMid(prefix, version string, staticRoute StaticRoute, withoutRoutePrefix bool) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		if !withoutRoutePrefix && strings.HasPrefix(context.Request.RequestURI, prefix) {
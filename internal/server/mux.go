@@ -5,12 +5,16 @@ import (
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/obnahsgnaw/api/internal/errhandler"
 	"github.com/obnahsgnaw/api/internal/marshaler"
+	"github.com/obnahsgnaw/api/internal/reqinfo"
 	"github.com/obnahsgnaw/api/pkg/errobj"
 	"github.com/obnahsgnaw/api/service"
+	"github.com/obnahsgnaw/api/service/tracing"
 	"github.com/obnahsgnaw/application/pkg/debug"
 	"google.golang.org/grpc/metadata"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func NewMux() *runtime.ServeMux {
@@ -24,9 +28,22 @@ func InitMux(mux *runtime.ServeMux, mdProviders *service.MethodMdProvider, middl
 		runtime.WithOutgoingHeaderMatcher(errhandler.OutgoingHeaderMatcher),
 		// trans header to metadata
 		runtime.WithMetadata(func(ctx context.Context, request *http.Request) metadata.MD {
+			if info, ok := reqinfo.From(ctx); ok {
+				if m, ok1 := runtime.RPCMethod(ctx); ok1 {
+					info.SetRpcMethod(m)
+				}
+			}
 			var metaData []string
+			// the trace context of the server span replaces the incoming one
+			traceMd := tracing.Inject(ctx)
+			for k, v := range traceMd {
+				metaData = append(metaData, k, v)
+			}
 			if mdProviders.All() || mdProviders.MethodAll(ctx) {
 				for k, v := range request.Header {
+					if _, ok := traceMd[strings.ToLower(k)]; ok {
+						continue
+					}
 					metaData = append(metaData, k, strings.Join(v, " "))
 				}
 			} else {
@@ -37,6 +54,9 @@ func InitMux(mux *runtime.ServeMux, mdProviders *service.MethodMdProvider, middl
 					metaData = append(metaData, key, val)
 				})
 			}
+			if dl, ok := ctx.Deadline(); ok {
+				metaData = append(metaData, "rq_timeout", strconv.FormatInt(time.Until(dl).Milliseconds(), 10))
+			}
 			md := metadata.Pairs(metaData...)
 			return md
 		}),
@@ -48,6 +68,12 @@ func InitMux(mux *runtime.ServeMux, mdProviders *service.MethodMdProvider, middl
 		runtime.WithMarshalerOption("application/octet-stream", marshaler.ProtoMarshaler()),
 		runtime.WithMarshalerOption("application/x-protobuf", marshaler.ProtoMarshaler()),
 	}
+	ops = append(ops, runtime.WithBeforeRoute(func(w http.ResponseWriter, r *http.Request, pathParams map[string]string, pattern string) bool {
+		if info, ok := reqinfo.From(r.Context()); ok {
+			info.SetPattern(NormalizePattern(pattern))
+		}
+		return true
+	}))
 	if len(middlewares) > 0 {
 		for _, m := range middlewares {
 			ops = append(ops, runtime.WithBeforeRoute(m))
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/service"
+	"github.com/obnahsgnaw/api/service/tracing"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc/metadata"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	incomingTraceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	incomingSpanId  = "00f067aa0ba902b7"
+)
+
+// newTracedMux return the gateway of the project demo, the handler records the outgoing metadata of the rpc call
+func newTracedMux(exporter *tracetest.InMemoryExporter, md *metadata.MD, all bool) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	mux := NewMux()
+	mdProvider := service.NewMdProvider()
+	if all {
+		mdProvider.AddAll()
+	}
+	InitMux(mux, mdProvider, nil, nil, nil)
+	_ = mux.HandlePath(http.MethodGet, "/v1/hello", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx, err := runtime.AnnotateContext(r.Context(), mux, r, "/demo.HelloService/Hello")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		*md, _ = metadata.FromOutgoingContext(ctx)
+	})
+	InitRpcHttpProxyServer(e, mux, "demo", "v1", nil, nil, false, tracing.New(exporter, tracing.SyncExport()))
+	return e
+}
+
+func TestTraceMetadata(t *testing.T) {
+	for _, all := range []bool{false, true} {
+		exporter := tracetest.NewInMemoryExporter()
+		var md metadata.MD
+		e := newTracedMux(exporter, &md, all)
+		rq := httptest.NewRequest(http.MethodGet, "/v1/demo/hello", nil)
+		rq.Header.Set("traceparent", "00-"+incomingTraceId+"-"+incomingSpanId+"-01")
+		serve(e, rq)
+
+		spans := exporter.GetSpans()
+		if len(spans) == 0 {
+			t.Fatal("server span not exported")
+		}
+		server := spans[len(spans)-1]
+		if server.SpanContext.TraceID().String() != incomingTraceId || server.Parent.SpanID().String() != incomingSpanId {
+			t.Fatal("server span not continued the incoming trace")
+		}
+		parents := md.Get("traceparent")
+		if len(parents) != 1 {
+			t.Fatal("trace context not in the metadata", md)
+		}
+		if !strings.HasPrefix(parents[0], "00-"+incomingTraceId+"-"+server.SpanContext.SpanID().String()) {
+			t.Fatal("metadata not carrying the server span: " + parents[0])
+		}
+	}
+}
+
+func TestTraceMetadataDisabled(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	mux := NewMux()
+	InitMux(mux, service.NewMdProvider(), nil, nil, nil)
+	var md metadata.MD
+	_ = mux.HandlePath(http.MethodGet, "/v1/hello", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		ctx, _ := runtime.AnnotateContext(r.Context(), mux, r, "/demo.HelloService/Hello")
+		md, _ = metadata.FromOutgoingContext(ctx)
+	})
+	InitRpcHttpProxyServer(e, mux, "demo", "v1", nil, nil, false, nil)
+	rq := httptest.NewRequest(http.MethodGet, "/v1/demo/hello", nil)
+	rq.Header.Set("traceparent", "00-"+incomingTraceId+"-"+incomingSpanId+"-01")
+	serve(e, rq)
+	if len(md.Get("traceparent")) != 0 {
+		t.Fatal("trace context passed without tracing")
+	}
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// AddProbeRoutes add the liveness and readiness probe routes
+func AddProbeRoutes(e *gin.Engine, healthz, readyz string, ready func(ctx context.Context) (bool, map[string]string)) {
+	e.GET(healthz, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	e.GET(readyz, func(c *gin.Context) {
+		ok, checks := ready(c.Request.Context())
+		if ok {
+			c.JSON(http.StatusOK, gin.H{"status": "ok", "checks": checks})
+		} else {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// MuxPattern a handler registered on the mux
+type MuxPattern struct {
+	Method    string
+	Pattern   string
+	RpcMethod string
+}
+
+var simpleCapture = regexp.MustCompile(`\{([^=}]+)=\*\}`)
+
+// NormalizePattern trans the mux pattern string like /v1/{id=*} to the http rule form /v1/{id}
+func NormalizePattern(pattern string) string {
+	return simpleCapture.ReplaceAllString(pattern, "{$1}")
+}
+
+// RpcMethods the gateway patterns of the muxes and their rpc methods, recorded when the handlers are registered
+type RpcMethods struct {
+	mu       sync.RWMutex
+	patterns map[*runtime.ServeMux][]MuxPattern
+	methods  map[string]string // method pattern => rpc method
+}
+
+func NewRpcMethods() *RpcMethods {
+	return &RpcMethods{
+		patterns: make(map[*runtime.ServeMux][]MuxPattern),
+		methods:  make(map[string]string),
+	}
+}
+
+// HandlePath register the handler on the mux and record the pattern
+func (m *RpcMethods) HandlePath(mux *runtime.ServeMux, meth, pathPattern string, h runtime.HandlerFunc) error {
+	if err := mux.HandlePath(meth, pathPattern, h); err != nil {
+		return err
+	}
+	m.Add(mux, meth, pathPattern, "")
+	return nil
+}
+
+// Add record a pattern of the mux, the rpc method is empty if not a gateway handler
+func (m *RpcMethods) Add(mux *runtime.ServeMux, meth, pattern, rpcMethod string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.patterns[mux] = append(m.patterns[mux], MuxPattern{Method: meth, Pattern: pattern, RpcMethod: rpcMethod})
+	if rpcMethod != "" {
+		m.methods[meth+" "+NormalizePattern(pattern)] = rpcMethod
+	}
+}
+
+// AddService record the http rules of the service registered on the mux by the generated Register*Handler, false if the
+// service is not found in the proto registry
+func (m *RpcMethods) AddService(mux *runtime.ServeMux, service string) bool {
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return false
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return false
+	}
+	m.addService(mux, sd)
+	return true
+}
+
+func (m *RpcMethods) addService(mux *runtime.ServeMux, sd protoreflect.ServiceDescriptor) {
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+		rpcMethod := "/" + string(sd.FullName()) + "/" + string(md.Name())
+		for _, r := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+			if meth, pattern := httpRulePattern(r); pattern != "" {
+				m.Add(mux, meth, pattern, rpcMethod)
+			}
+		}
+	}
+}
+
+func httpRulePattern(r *annotations.HttpRule) (string, string) {
+	switch p := r.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return http.MethodGet, p.Get
+	case *annotations.HttpRule_Put:
+		return http.MethodPut, p.Put
+	case *annotations.HttpRule_Post:
+		return http.MethodPost, p.Post
+	case *annotations.HttpRule_Delete:
+		return http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Patch:
+		return http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Custom:
+		return p.Custom.GetKind(), p.Custom.GetPath()
+	}
+	return "", ""
+}
+
+// Patterns list the recorded patterns of the mux
+func (m *RpcMethods) Patterns(mux *runtime.ServeMux) []MuxPattern {
+	m.mu.RLock()
+	patterns := append([]MuxPattern(nil), m.patterns[mux]...)
+	m.mu.RUnlock()
+	sort.Slice(patterns, func(i, j int) bool {
+		if patterns[i].Pattern != patterns[j].Pattern {
+			return patterns[i].Pattern < patterns[j].Pattern
+		}
+		return patterns[i].Method < patterns[j].Method
+	})
+	return patterns
+}
+
+// Get return the rpc method of the http method and the pattern, empty if not a gateway handler
+func (m *RpcMethods) Get(httpMethod, pattern string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.methods[httpMethod+" "+NormalizePattern(pattern)]
+}
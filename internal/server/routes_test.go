@@ -0,0 +1,72 @@
+package server
+
+import (
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"net/http"
+	"testing"
+)
+
+func TestMuxPatterns(t *testing.T) {
+	mux := NewMux()
+	methods := NewRpcMethods()
+	h := func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {}
+	if err := methods.HandlePath(mux, "GET", "/v1/users/{id}", h); err != nil {
+		t.Fatal(err)
+	}
+	if err := methods.HandlePath(mux, "POST", "/v1/users", h); err != nil {
+		t.Fatal(err)
+	}
+
+	patterns := methods.Patterns(mux)
+	if len(patterns) != 2 {
+		t.Fatal("mux patterns not recorded")
+	}
+	if patterns[1].Method != "GET" || patterns[1].Pattern != "/v1/users/{id}" || patterns[1].RpcMethod != "" {
+		t.Fatal("unexpected pattern: " + patterns[1].Method + " " + patterns[1].Pattern)
+	}
+}
+
+func TestRpcMethods(t *testing.T) {
+	get := &descriptorpb.MethodOptions{}
+	proto.SetExtension(get, annotations.E_Http, &annotations.HttpRule{
+		Pattern:            &annotations.HttpRule_Get{Get: "/v1/users/{id}"},
+		AdditionalBindings: []*annotations.HttpRule{{Pattern: &annotations.HttpRule_Get{Get: "/v1/members/{id=*}"}}},
+	})
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("demo/user.proto"),
+		Package: proto.String("demo"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Req")},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("UserService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("Get"), InputType: proto.String(".demo.Req"), OutputType: proto.String(".demo.Req"), Options: get},
+				{Name: proto.String("Internal"), InputType: proto.String(".demo.Req"), OutputType: proto.String(".demo.Req")},
+			},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := NewMux()
+	methods := NewRpcMethods()
+	methods.addService(mux, fd.Services().Get(0))
+	if patterns := methods.Patterns(mux); len(patterns) != 2 {
+		t.Fatal("http rules not recorded")
+	}
+	if m := methods.Get("GET", "/v1/users/{id=*}"); m != "/demo.UserService/Get" {
+		t.Fatal("unexpected rpc method: " + m)
+	}
+	if m := methods.Get("GET", "/v1/members/{id}"); m != "/demo.UserService/Get" {
+		t.Fatal("additional binding not recorded")
+	}
+	if methods.AddService(mux, "demo.NotExist") {
+		t.Fatal("unknown service added")
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/internal/marshaler"
+	"github.com/obnahsgnaw/api/pkg/apierr"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewVersionMid mark the response with the api version, and the Deprecation/Sunset headers for the old version
+func NewVersionMid(version string, deprecation, sunset time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Api-Version", version)
+		if !deprecation.IsZero() {
+			c.Header("Deprecation", "@"+strconv.FormatInt(deprecation.Unix(), 10))
+		}
+		if !sunset.IsZero() {
+			c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		c.Next()
+	}
+}
+
+// NewNegotiationEngine return an engine to serve the negotiated requests, the version routes should be added to it as well.
+// It only holds the version routes, the middlewares of the engine e are not run again
+func NewNegotiationEngine(e *gin.Engine) *gin.Engine {
+	n := gin.New()
+	n.RedirectTrailingSlash = e.RedirectTrailingSlash
+	n.RedirectFixedPath = e.RedirectFixedPath
+	n.UseRawPath = e.UseRawPath
+	n.UnescapePathValues = e.UnescapePathValues
+	n.RemoveExtraSlash = e.RemoveExtraSlash
+	n.ContextWithFallback = e.ContextWithFallback
+	return n
+}
+
+type clientIpKey struct{}
+
+// VersionRouter route the requests without version prefix of the projects on an engine, from the NoRoute chain of the engine
+type VersionRouter struct {
+	mu       sync.RWMutex
+	projects map[string]gin.HandlerFunc
+}
+
+// NewVersionRouter return a version router installed in front of the NoRoute handlers of e, which still serve the requests of no
+// project. The Use or NoRoute of e rebuild the NoRoute chain without it, call them before
+func NewVersionRouter(e *gin.Engine) *VersionRouter {
+	r := &VersionRouter{projects: make(map[string]gin.HandlerFunc)}
+	base := e.Handlers
+	e.Handlers = append(append(gin.HandlersChain{}, base...), r.handle)
+	// rebuild the NoRoute chain with the engine handlers, the NoRoute handlers kept
+	e.Use()
+	e.Handlers = base
+	return r
+}
+
+func (r *VersionRouter) handle(c *gin.Context) {
+	path := c.Request.URL.Path
+	if len(path) < 2 {
+		return
+	}
+	project := path
+	if i := strings.IndexByte(path[1:], '/'); i >= 0 {
+		project = path[:i+1]
+	}
+	r.mu.RLock()
+	h, ok := r.projects[project]
+	r.mu.RUnlock()
+	if ok {
+		h(c)
+	}
+}
+
+// Add route the requests without version prefix, /{project}/xxx, to the version from the Accept-Version header, or the default version.
+// The requests are served by the negotiation engine n, so the ext routes under /{project} keep working
+func (r *VersionRouter) Add(n *gin.Engine, project string, versions []string, defVersion string, errHandle func(err error, marshaler runtime.Marshaler, w http.ResponseWriter)) {
+	supported := make(map[string]struct{}, len(versions))
+	for _, v := range versions {
+		supported[v] = struct{}{}
+	}
+	handler := func(c *gin.Context) {
+		version := strings.ToLower(strings.TrimSpace(c.GetHeader("Accept-Version")))
+		if version == "" {
+			version = defVersion
+		} else if !strings.HasPrefix(version, "v") {
+			version = "v" + version
+		}
+		c.Header("Vary", "Accept-Version")
+		if _, ok := supported[version]; !ok {
+			c.Abort()
+			errHandle(
+				apierr.ToStatusError(apierr.NewValidateError("unsupported api version: "+version)),
+				marshaler.GetMarshaler(c.GetHeader("Accept")),
+				c.Writer,
+			)
+			return
+		}
+		c.Request.URL.Path = "/" + version + c.Request.URL.Path
+		if c.Request.URL.RawPath != "" {
+			c.Request.URL.RawPath = "/" + version + c.Request.URL.RawPath
+		}
+		c.Request.RequestURI = "/" + version + c.Request.RequestURI
+		// the client ip is resolved by e, n does not know its trusted proxies
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), clientIpKey{}, c.ClientIP()))
+		n.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+	r.mu.Lock()
+	r.projects["/"+project] = handler
+	r.mu.Unlock()
+}
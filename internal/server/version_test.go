@@ -0,0 +1,81 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newVersionEngine() *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.NoRoute(func(c *gin.Context) {
+		c.String(http.StatusTeapot, "app not found")
+	})
+	n := NewNegotiationEngine(e)
+	deprecation := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	n.GET("/v1/demo/hello", NewVersionMid("v1", deprecation, sunset), func(c *gin.Context) {
+		c.String(http.StatusOK, "v1")
+	})
+	n.GET("/v2/demo/hello", NewVersionMid("v2", time.Time{}, time.Time{}), func(c *gin.Context) {
+		c.String(http.StatusOK, "v2")
+	})
+	r := NewVersionRouter(e)
+	r.Add(n, "demo", []string{"v1", "v2"}, "v2", func(err error, _ runtime.Marshaler, w http.ResponseWriter) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+	})
+	return e
+}
+
+func serve(e *gin.Engine, rq *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, rq)
+	return w
+}
+
+func TestVersionNegotiation(t *testing.T) {
+	e := newVersionEngine()
+
+	w := serve(e, httptest.NewRequest(http.MethodGet, "/demo/hello", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "v2" || w.Header().Get("Api-Version") != "v2" {
+		t.Fatal("default version not served", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Vary") != "Accept-Version" || w.Header().Get("Deprecation") != "" {
+		t.Fatal("unexpected headers", w.Header())
+	}
+
+	rq := httptest.NewRequest(http.MethodGet, "/demo/hello", nil)
+	rq.Header.Set("Accept-Version", "1")
+	w = serve(e, rq)
+	if w.Code != http.StatusOK || w.Body.String() != "v1" {
+		t.Fatal("accepted version not served", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Deprecation") != "@1767225600" || w.Header().Get("Sunset") != "Fri, 01 Jan 2027 00:00:00 GMT" {
+		t.Fatal("deprecation headers not set", w.Header())
+	}
+
+	rq = httptest.NewRequest(http.MethodGet, "/demo/hello", nil)
+	rq.Header.Set("Accept-Version", "v9")
+	if w = serve(e, rq); w.Code != http.StatusBadRequest {
+		t.Fatal("unsupported version not rejected", w.Code)
+	}
+}
+
+func TestVersionRouterFallback(t *testing.T) {
+	e := newVersionEngine()
+	if w := serve(e, httptest.NewRequest(http.MethodGet, "/other/hello", nil)); w.Code != http.StatusTeapot {
+		t.Fatal("NoRoute handler of the app not kept", w.Code)
+	}
+
+	rq := httptest.NewRequest(http.MethodGet, "/", nil)
+	rq.Method = http.MethodConnect
+	rq.URL.Path = ""
+	if w := serve(e, rq); w.Code != http.StatusTeapot {
+		t.Fatal("empty path not passed to the NoRoute handler", w.Code)
+	}
+}
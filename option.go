@@ -2,23 +2,60 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/obnahsgnaw/api/internal/middleware/auditmid"
 	"github.com/obnahsgnaw/api/internal/middleware/authmid"
+	"github.com/obnahsgnaw/api/internal/middleware/breakermid"
+	"github.com/obnahsgnaw/api/internal/middleware/cachemid"
 	"github.com/obnahsgnaw/api/internal/middleware/commonmid"
+	"github.com/obnahsgnaw/api/internal/middleware/deadlinemid"
+	"github.com/obnahsgnaw/api/internal/middleware/idempotencymid"
 	"github.com/obnahsgnaw/api/internal/middleware/permmid"
+	"github.com/obnahsgnaw/api/internal/middleware/ratelimitmid"
 	"github.com/obnahsgnaw/api/pkg/apierr"
 	"github.com/obnahsgnaw/api/pkg/errobj"
+	"github.com/obnahsgnaw/api/pkg/jwt"
+	"github.com/obnahsgnaw/api/pkg/pipeline"
 	"github.com/obnahsgnaw/api/service"
 	"github.com/obnahsgnaw/api/service/apidoc"
+	"github.com/obnahsgnaw/api/service/audit"
 	"github.com/obnahsgnaw/api/service/authedapp"
 	"github.com/obnahsgnaw/api/service/autheduser"
+	"github.com/obnahsgnaw/api/service/breaker"
+	"github.com/obnahsgnaw/api/service/compress"
+	"github.com/obnahsgnaw/api/service/cors"
 	"github.com/obnahsgnaw/api/service/crypt"
+	"github.com/obnahsgnaw/api/service/httpcache"
+	"github.com/obnahsgnaw/api/service/idempotency"
+	"github.com/obnahsgnaw/api/service/metrics"
 	"github.com/obnahsgnaw/api/service/perm"
+	"github.com/obnahsgnaw/api/service/ratelimit"
 	"github.com/obnahsgnaw/api/service/sign"
+	"github.com/obnahsgnaw/api/service/tracing"
 	"github.com/obnahsgnaw/rpc"
+	"time"
 )
 
 type Option func(s *Server)
 
+// built-in middleware priorities, smaller runs first, custom middlewares default to pipeline.DefaultPriority
+const (
+	AppMidPriority         = 100
+	AuthMidPriority        = 200
+	SignMidPriority        = 300
+	CryptMidPriority       = 400
+	PermMidPriority        = 100
+	RateLimitMidPriority   = 50
+	BreakerMidPriority     = 150
+	DeadlineMidPriority    = 10
+	CorsMidPriority        = 1
+	CompressMidPriority    = 5
+	IdempotencyMidPriority = 500
+	CacheMidPriority       = 600
+	CacheHitMidPriority    = 120
+	AuditMidPriority       = 0
+	MetricsMidPriority     = 0
+)
+
 func RegEnable() Option {
 	return func(s *Server) {
 		s.regEnable = true
@@ -30,16 +67,18 @@ func AppMiddleware(m *authedapp.Manager) Option {
 			return authmid.NewAppMid(m, func(msg string) {
 				s.logger.Debug(msg)
 			}, s.ErrorHandler())
-		}, false)
+		}, false, pipeline.Priority(AppMidPriority))
 	}
 }
+
+// CryptMiddleware decrypt the request body and encrypt the response body, the clients decrypt the response
 func CryptMiddleware(m *crypt.Manager) Option {
 	return func(s *Server) {
 		s.AddMiddleware("crypt", func() gin.HandlerFunc {
 			return authmid.NewCryptMid(m, func(msg string) {
 				s.logger.Debug(msg)
 			}, s.ErrorHandler())
-		}, false)
+		}, false, pipeline.Priority(CryptMidPriority), pipeline.After("auth"))
 	}
 }
 func AuthMiddleware(m *autheduser.Manager) Option {
@@ -48,16 +87,17 @@ func AuthMiddleware(m *autheduser.Manager) Option {
 			return authmid.NewAuthMid(m, func(msg string) {
 				s.logger.Debug(msg)
 			}, s.ErrorHandler())
-		}, false)
+		}, false, pipeline.Priority(AuthMidPriority), pipeline.After("app"), pipeline.Before("crypt"))
 	}
 }
 func SignMiddleware(m *sign.Manager) Option {
 	return func(s *Server) {
+		s.signHeaderKey = m.SignHeaderKey()
 		s.AddMiddleware("sign", func() gin.HandlerFunc {
 			return authmid.NewSignMid(m, func(msg string) {
 				s.logger.Debug(msg)
 			}, s.ErrorHandler())
-		}, false)
+		}, false, pipeline.Priority(SignMidPriority), pipeline.After("auth"))
 	}
 }
 func PermMiddleware(m *perm.Manager) Option {
@@ -66,12 +106,166 @@ func PermMiddleware(m *perm.Manager) Option {
 			return permmid.NewMuxPermissionMid(m, func(msg string) {
 				s.logger.Debug(msg)
 			}, s.ErrorHandler())
-		}, false)
+		}, false, pipeline.Priority(PermMidPriority))
+	}
+}
+
+// RateLimitMiddleware limit the requests by the rules of the manager, runs as a mux middleware before perm
+func RateLimitMiddleware(m *ratelimit.Manager) Option {
+	return func(s *Server) {
+		m.With(ratelimit.RpcMethodResolver(s.rpcMethods.Get))
+		s.optionChecks = append(s.optionChecks, m.Err)
+		s.AddMuxMiddleware("ratelimit", func() service.MuxRouteHandleFunc {
+			return ratelimitmid.NewMuxRateLimitMid(m, func(msg string) {
+				s.logger.Debug(msg)
+			}, s.ErrorHandler())
+		}, false, pipeline.Priority(RateLimitMidPriority), pipeline.Before("perm"))
+	}
+}
+
+// BreakerMiddleware limit the concurrent calls and break the failing calls per pattern or rpc method, runs as a mux middleware after perm
+func BreakerMiddleware(m *breaker.Manager) Option {
+	return func(s *Server) {
+		m.With(breaker.RpcMethodResolver(s.rpcMethods.Get))
+		s.breaker = m
+		s.AddMuxMiddleware("breaker", func() service.MuxRouteHandleFunc {
+			return breakermid.NewMuxBreakerMid(m, func(msg string) {
+				s.logger.Debug(msg)
+			}, s.ErrorHandler())
+		}, false, pipeline.Priority(BreakerMidPriority), pipeline.After("perm", "ratelimit"))
+	}
+}
+
+// Cors answer the preflight requests and set the cors headers for the gateway and the ext routes, runs before app,
+// the X-Request-Id, the sign header and the Grpc-Metadata-* headers are exposed
+func Cors(config cors.Config) Option {
+	return func(s *Server) {
+		s.cors = &config
+		s.AddMiddleware(extMidCors, s.corsMid, false, pipeline.Priority(CorsMidPriority), pipeline.Before("app"))
+	}
+}
+
+// Compression compress the gateway and ext route responses negotiated by Accept-Encoding, and decompress the gzip request bodies,
+// runs before crypt so that the request is decompressed before decrypted and the response compressed after encrypted
+func Compression(config compress.Config) Option {
+	return func(s *Server) {
+		s.compress = &config
+		s.AddMiddleware(extMidCompress, s.compressMid, false, pipeline.Priority(CompressMidPriority), pipeline.Before("crypt"))
+	}
+}
+
+// IdempotencyMiddleware replay the stored responses of the POST, PUT and PATCH gateway requests with the same Idempotency-Key,
+// runs after auth, sign and crypt
+func IdempotencyMiddleware(m *idempotency.Manager) Option {
+	return func(s *Server) {
+		s.AddMiddleware("idempotency", func() gin.HandlerFunc {
+			return idempotencymid.NewIdempotencyMid(m, func(msg string) {
+				s.logger.Debug(msg)
+			}, s.ErrorHandler())
+		}, false, pipeline.Priority(IdempotencyMidPriority), pipeline.After("auth", "sign", "crypt"))
+	}
+}
+
+// HttpCache set the ETag of the GET gateway responses and answer the conditional requests with 304, cache the responses when
+// the server side cache enabled, runs after auth, sign and crypt. The cached responses are read by a mux middleware after perm
+// and ratelimit, the requests rejected by them are never answered from the cache
+func HttpCache(m *httpcache.Manager) Option {
+	return func(s *Server) {
+		s.AddMiddleware("cache", func() gin.HandlerFunc {
+			return cachemid.NewCacheMid(m, func(msg string) {
+				s.logger.Debug(msg)
+			})
+		}, false, pipeline.Priority(CacheMidPriority), pipeline.After("auth", "sign", "crypt", "idempotency"))
+		s.AddMuxMiddleware("cache", func() service.MuxRouteHandleFunc {
+			return cachemid.NewMuxCacheMid(m, func(msg string) {
+				s.logger.Debug(msg)
+			})
+		}, false, pipeline.Priority(CacheHitMidPriority), pipeline.After("perm", "ratelimit"), pipeline.Before("breaker"))
+	}
+}
+
+// AuditMiddleware emit an audit record of each gateway request by the sink of the manager, the server logger by default,
+// runs first to audit the rejected requests as well
+func AuditMiddleware(m *audit.Manager) Option {
+	return func(s *Server) {
+		if m.Sink() == nil {
+			m.With(audit.WithSink(audit.NewZapSink(s.logger.Named("audit"))))
+		}
+		s.AddMiddleware("audit", func() gin.HandlerFunc {
+			return auditmid.NewAuditMid(m, func(msg string) {
+				s.logger.Debug(msg)
+			})
+		}, false, pipeline.Priority(AuditMidPriority))
+	}
+}
+
+// Metrics collect the request, in-flight and middleware rejection metrics of the gateway and the ext routes, and the registry status,
+// runs first to count the rejected requests as well, see MetricsRoute to expose them
+func Metrics(m *metrics.Manager) Option {
+	return func(s *Server) {
+		s.metrics = m
+		if err := m.Register(); err != nil {
+			s.logger.Warn("metrics register failed, err=" + err.Error())
+		}
+		var states []string
+		for st := RegPending; st <= RegDisabled; st++ {
+			states = append(states, st.String())
+		}
+		if err := m.WatchRegistry(states, func() string {
+			return s.RegStatus().State.String()
+		}); err != nil {
+			s.logger.Warn("metrics registry status register failed, err=" + err.Error())
+		}
+		s.AddMiddleware(extMidMetrics, s.metricsMid, false, pipeline.Priority(MetricsMidPriority))
+	}
+}
+
+// MetricsRoute expose the metrics of the Metrics option at the path on the engine, default /metrics
+func MetricsRoute(path string) Option {
+	return func(s *Server) {
+		if path == "" {
+			path = "/metrics"
+		}
+		s.AddRoute(func() service.RouteProvider {
+			return s.metricsRoute(path)
+		})
+	}
+}
+
+// Jwks publish the public keys of the keyring as a JWKS document at the path, default /{id}-jwks, for the other services to verify
+// the tokens signed by the keyring, the HS256 secrets are never published
+func Jwks(ring *jwt.Keyring, path string) Option {
+	return func(s *Server) {
+		if path == "" {
+			path = "/" + s.id + "-jwks"
+		}
+		s.AddRoute(func() service.RouteProvider {
+			return s.jwksRoute(ring, path)
+		})
+	}
+}
+
+// Tracing start a server span for each gateway request continuing the W3C trace context of the request, pass the trace context to
+// the rpc services by the metadata, and add the child spans of the built-in middlewares. The spans are flushed when the server
+// released, call Manager.Shutdown at exit
+func Tracing(m *tracing.Manager) Option {
+	return func(s *Server) {
+		if m.ServiceName() == "" {
+			m.With(tracing.ServiceName(s.id))
+		}
+		s.tracing = m
 	}
 }
+
+// Gateway register the http host under the key of keyGen, the readiness waits for it. The key is fetched again by the watcher and
+// the changed one is registered, an empty key means no gateway and the readiness does not wait for it
 func Gateway(keyGen func() (string, error)) Option {
 	return func(s *Server) {
 		s.gatewayKeyGen = keyGen
+		if keyGen != nil {
+			// ready after the gateway registered
+			s.readiness.set("gateway", false)
+		}
 	}
 }
 func ErrObjProvider(p errobj.Provider) Option {
@@ -107,10 +301,113 @@ func WithoutRoutePrefix() Option {
 		s.withoutRoutePrefix = true
 	}
 }
-func CommonMiddleware(name string, handler func(c *gin.Context, rqId, rqType string, debugger func(string)) error) Option {
+func CommonMiddleware(name string, handler func(c *gin.Context, rqId, rqType string, debugger func(string)) error, rules ...pipeline.Rule) Option {
 	return func(s *Server) {
 		s.AddMiddleware(name, func() gin.HandlerFunc {
 			return commonmid.NewCommonMid(handler, func(msg string) { s.logger.Debug(msg) }, s.ErrorHandler())
-		}, false)
+		}, false, rules...)
+	}
+}
+
+// DrainTimeout the max time to wait the in-flight requests when release
+func DrainTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		if timeout >= 0 {
+			s.drainTimeout = timeout
+		}
+	}
+}
+
+// ReadinessChecker add a readiness checker for the /{id}-readyz probe, such as the Ping of a jwt.KeyStore
+func ReadinessChecker(name string, checker ReadyChecker) Option {
+	return func(s *Server) {
+		s.AddReadyChecker(name, checker)
+	}
+}
+
+// RegWatch watch the registrations every interval, re-register the lost ones checked by the checker, or re-put them all without a checker,
+// and re-put the gateway key, interval <= 0 disable it
+func RegWatch(interval time.Duration, checker RegChecker) Option {
+	return func(s *Server) {
+		s.watcher.interval = interval
+		s.watcher.checker = checker
+	}
+}
+
+// WatchHook add a registration watch event hook
+func WatchHook(hook func(WatchEvent)) Option {
+	return func(s *Server) {
+		s.OnWatchEvent(hook)
+	}
+}
+
+// RequestTimeout set the default and the max timeout of the gateway requests, clients may send X-Request-Timeout or Grpc-Timeout to shorten it,
+// the timeout is applied to the grpc call context, 0 means no limit
+func RequestTimeout(def, max time.Duration) Option {
+	return func(s *Server) {
+		cnf := s.deadlineConfig()
+		cnf.Default = def
+		cnf.Max = max
+	}
+}
+
+// PatternTimeout set the default timeout of the gateway pattern, such as GET /v1/users/{id}
+func PatternTimeout(method, pattern string, timeout time.Duration) Option {
+	return func(s *Server) {
+		s.deadlineConfig().SetPattern(method, pattern, timeout)
+	}
+}
+
+func (s *Server) deadlineConfig() *deadlinemid.Config {
+	if s.deadline == nil {
+		s.deadline = deadlinemid.NewConfig()
+		s.AddMuxMiddleware("deadline", func() service.MuxRouteHandleFunc {
+			return deadlinemid.NewMuxDeadlineMid(s.deadline, func(msg string) {
+				s.logger.Debug(msg)
+			}, s.ErrorHandler())
+		}, false, pipeline.Priority(DeadlineMidPriority))
+	}
+	return s.deadline
+}
+
+// StateHook add a lifecycle state change hook
+func StateHook(hook func(from, to State)) Option {
+	return func(s *Server) {
+		s.OnStateChange(hook)
+	}
+}
+
+// RegRetry set the registration retry policy, see DefaultRegRetryPolicy
+func RegRetry(policy RegRetryPolicy) Option {
+	return func(s *Server) {
+		s.regRetry = policy
+	}
+}
+
+// ApiVersion mount another api version beside the default one, or set the options of a mounted version
+func ApiVersion(v Version, options ...VersionOption) Option {
+	return func(s *Server) {
+		s.AddVersion(v, options...)
+	}
+}
+
+// RouteDebug list the routes at the path in debug mode, default /{id}-routes
+func RouteDebug(path string) Option {
+	return func(s *Server) {
+		if path == "" {
+			path = "/" + s.id + "-routes"
+		}
+		s.AddRoute(func() service.RouteProvider {
+			return s.routeDebugRoute(path)
+		})
+	}
+}
+
+// AdminApi mount the middleware admin api at /{id}-admin, accessible in debug mode or with the token in the X-Admin-Token header
+func AdminApi(token string) Option {
+	return func(s *Server) {
+		s.AddRoute(func() service.RouteProvider {
+			return s.adminRoute("/"+s.id+"-admin", token)
+		})
 	}
 }
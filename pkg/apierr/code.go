@@ -19,6 +19,14 @@ var (
 	SignMidGenFailed  = NewCommonErrCode(16, "signature generate failed")
 	PermMidNoPerm     = NewCommonErrCode(17, "no permission")
 	RpcFailed         = NewCommonErrCode(18, "rpc call failed")
+	ServerUnavailable = NewCommonErrCode(19, "server unavailable")
+	RateLimited       = NewCommonErrCode(20, "too many requests")
+	ServerBusy        = NewCommonErrCode(21, "too many concurrent requests")
+	CircuitOpen       = NewCommonErrCode(22, "service temporarily unavailable")
+	RequestTimeout    = NewCommonErrCode(23, "request timeout")
+	DecompressFailed  = NewCommonErrCode(24, "request body decompress failed")
+	IdempotencyReused = NewCommonErrCode(25, "idempotency key reused by a different request")
+	IdempotencyBusy   = NewCommonErrCode(26, "request of the idempotency key in progress")
 )
 
 // ErrCode 错误码
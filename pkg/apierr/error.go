@@ -107,11 +107,21 @@ func NewConflictError() *ApiError {
 	return NewApiErr(StatusConflict, ConflictError, nil)
 }
 
+// NewCodeConflictError 指定错误码的冲突错误
+func NewCodeConflictError(code ErrCode, err error) *ApiError {
+	return NewApiErr(StatusConflict, code, err)
+}
+
 // NewLockedError 锁定错误
 func NewLockedError(code ErrCode) *ApiError {
 	return NewApiErr(StatusLocked, code, nil)
 }
 
+// NewTooManyRequestsError 请求过多错误
+func NewTooManyRequestsError(code ErrCode, err error) *ApiError {
+	return NewApiErr(StatusTooManyRequests, code, err)
+}
+
 // NewCommonInternalError 通用内部错误
 func NewCommonInternalError(err error) *ApiError {
 	return NewApiErr(StatusInternalServerError, InternalError, err)
@@ -122,6 +132,16 @@ func NewInternalError(code ErrCode, err error) *ApiError {
 	return NewApiErr(StatusInternalServerError, code, err)
 }
 
+// NewServiceUnavailableError 服务不可用错误
+func NewServiceUnavailableError(code ErrCode, err error) *ApiError {
+	return NewApiErr(StatusServiceUnavailable, code, err)
+}
+
+// NewGatewayTimeoutError 请求超时错误
+func NewGatewayTimeoutError(code ErrCode, err error) *ApiError {
+	return NewApiErr(StatusGatewayTimeout, code, err)
+}
+
 // ToStatusError 转换成runtime.HTTPStatusError
 func ToStatusError(err error) *runtime.HTTPStatusError {
 	if err == nil {
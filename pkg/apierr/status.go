@@ -23,5 +23,8 @@ const (
 	StatusNotFound            HttpStatus = http.StatusNotFound
 	StatusConflict            HttpStatus = http.StatusConflict
 	StatusLocked              HttpStatus = http.StatusLocked
+	StatusTooManyRequests     HttpStatus = http.StatusTooManyRequests
 	StatusInternalServerError HttpStatus = http.StatusInternalServerError
+	StatusServiceUnavailable  HttpStatus = http.StatusServiceUnavailable
+	StatusGatewayTimeout      HttpStatus = http.StatusGatewayTimeout
 )
@@ -0,0 +1,78 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// FileKeyStore memory key storage persisted to a json file after each change and loaded at start, the keys survive the restarts
+// of a single node, not shared between instances. The file holds the secrets, written with the 0600 mode
+type FileKeyStore struct {
+	*MemoryKeyStore
+	path string
+}
+
+// NewFileKeyStore return a file key storage of the path, the keys are prefixed by prefix and ':', no prefix if empty
+func NewFileKeyStore(path, prefix string) (*FileKeyStore, error) {
+	s := &FileKeyStore{MemoryKeyStore: NewMemoryKeyStore(prefix), path: path}
+	if err := s.load(); err != nil {
+		return nil, jwtStoreError("load keys failed", err)
+	}
+	s.persist = s.save
+	return s, nil
+}
+
+// Ping check the directory of the file
+func (s *FileKeyStore) Ping(_ context.Context) error {
+	if _, err := os.Stat(filepath.Dir(s.path)); err != nil {
+		return jwtStoreError("ping key storage failed", err)
+	}
+	return nil
+}
+
+func (s *FileKeyStore) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(data, &s.items); err != nil {
+		return err
+	}
+	if s.items == nil {
+		s.items = make(map[string]*memoryItem)
+	}
+	for k := range s.items {
+		s.get(k)
+	}
+	return nil
+}
+
+// save write to a temp file and rename, the file is never half written
+func (s *FileKeyStore) save(items map[string]*memoryItem) error {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,170 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"strconv"
+)
+
+// Jwk a json web key of a public key
+type Jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// Jwks a json web key set
+type Jwks struct {
+	Keys []Jwk `json:"keys"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// Jwks return the json web key set of the public keys not retired, the HS256 secrets are excluded
+func (r *Keyring) Jwks() Jwks {
+	set := Jwks{Keys: []Jwk{}}
+	for _, k := range r.Keys() {
+		if jwk, ok := k.Jwk(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// Jwk return the json web key of the public key, false for the HS256 secrets
+func (k *Key) Jwk() (Jwk, bool) {
+	jwk := Jwk{Kid: k.Kid, Use: "sig", Alg: k.Method.Alg()}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64.EncodeToString(pub.N.Bytes())
+		jwk.E = b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64.EncodeToString(pub)
+	default:
+		return Jwk{}, false
+	}
+	return jwk, true
+}
+
+// ParseJwks return the verify only keys of the json web key set, the keys of unsupported types are skipped
+func ParseJwks(data []byte) ([]*Key, error) {
+	var set Jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	var keys []*Key
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		k, err := jwk.Key()
+		if err != nil {
+			return nil, err
+		}
+		if k != nil {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// Key return the verify only key of the json web key, nil if the type is not supported
+func (j Jwk) Key() (*Key, error) {
+	switch j.Kty {
+	case "RSA", "EC":
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+	alg := j.Alg
+	if alg == "" {
+		alg = defaultAlg(j.Kty, j.Crv)
+	}
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, jwtError("unsupported jwk alg " + alg)
+	}
+
+	var public interface{}
+	switch j.Kty {
+	case "RSA":
+		n, err := b64.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		x, err := b64.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		curve := curveOf(method)
+		if j.Crv != "" && j.Crv != curve.Params().Name {
+			return nil, jwtError("jwk crv " + j.Crv + " not match the alg " + alg)
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, jwtError("jwk point not on the curve " + curve.Params().Name)
+		}
+		public = pub
+	case "OKP":
+		x, err := b64.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, jwtError("jwk x length " + strconv.Itoa(len(x)) + " invalid for Ed25519")
+		}
+		public = ed25519.PublicKey(x)
+	}
+	return NewPublicKey(j.Kid, method, public)
+}
+
+func defaultAlg(kty, crv string) string {
+	switch kty {
+	case "RSA":
+		return "RS256"
+	case "EC":
+		switch crv {
+		case "P-384":
+			return "ES384"
+		case "P-521":
+			return "ES512"
+		default:
+			return "ES256"
+		}
+	default:
+		return "EdDSA"
+	}
+}
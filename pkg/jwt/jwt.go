@@ -23,6 +23,7 @@ type Userinfo struct {
 type ZyClaims struct {
 	jwt.RegisteredClaims
 	Userinfo Userinfo `json:"userinfo"`
+	Sid      string   `json:"sid,omitempty"` // session id of the session tokens
 }
 
 func (c ZyClaims) Valid() error {
@@ -46,11 +47,29 @@ func (c ZyClaims) Valid() error {
 }
 
 func GenerateToken(subject string, key []byte, issuer string, userinfo Userinfo, notBefore *jwt.NumericDate, ttl time.Duration) (string, error) {
+	token := jwt.NewWithClaims(SignMethod, newClaims(subject, issuer, userinfo, notBefore, ttl))
+
+	return token.SignedString(key)
+}
+
+// GenerateTokenWithKeyring sign the token by the signing key of the keyring, the key id is set to the kid header
+func GenerateTokenWithKeyring(subject string, ring *Keyring, issuer string, userinfo Userinfo, notBefore *jwt.NumericDate, ttl time.Duration) (string, error) {
+	key := ring.Signing()
+	if key == nil || !key.CanSign() {
+		return "", jwtError("keyring has no signing key")
+	}
+	token := jwt.NewWithClaims(key.Method, newClaims(subject, issuer, userinfo, notBefore, ttl))
+	token.Header["kid"] = key.Kid
+
+	return token.SignedString(key.private)
+}
+
+func newClaims(subject, issuer string, userinfo Userinfo, notBefore *jwt.NumericDate, ttl time.Duration) ZyClaims {
 	now := time.Now()
 	if notBefore == nil {
 		notBefore = jwt.NewNumericDate(now)
 	}
-	c := ZyClaims{
+	return ZyClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    issuer,
 			Subject:   subject,
@@ -62,24 +81,48 @@ func GenerateToken(subject string, key []byte, issuer string, userinfo Userinfo,
 		},
 		Userinfo: userinfo,
 	}
-	token := jwt.NewWithClaims(SignMethod, c)
-
-	return token.SignedString(key)
 }
 
 func ValidateToken(subject, issuer, tokenString string, keyProvider func(claims *ZyClaims) ([]byte, error)) (Userinfo, error) {
+	return validate(subject, issuer, tokenString, func(token *jwt.Token, claims *ZyClaims) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		key, err := keyProvider(claims)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) == 0 {
+			return nil, jwtError("token key not found, revoked or expired")
+		}
+		return key, nil
+	})
+}
+
+// ValidateTokenWithKeyring validate the token by the key of the kid header in the keyring, the alg must match the key
+func ValidateTokenWithKeyring(subject, issuer, tokenString string, ring *Keyring) (Userinfo, error) {
+	return validate(subject, issuer, tokenString, func(token *jwt.Token, _ *ZyClaims) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, jwtError("token kid empty")
+		}
+		key, ok := ring.Key(kid)
+		if !ok {
+			return nil, jwtError("token key not found: " + kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.public, nil
+	})
+}
+
+func validate(subject, issuer, tokenString string, keyFunc func(token *jwt.Token, claims *ZyClaims) (interface{}, error)) (Userinfo, error) {
 	var claims *ZyClaims
 	var ok bool
 	token, err := jwt.ParseWithClaims(tokenString, &ZyClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok = token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
 		if claims, ok = token.Claims.(*ZyClaims); ok {
-			if key, err := keyProvider(claims); err == nil {
-				return key, nil
-			} else {
-				return nil, err
-			}
+			return keyFunc(token, claims)
 		}
 
 		return nil, jwtError("unexpected payload")
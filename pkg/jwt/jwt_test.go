@@ -1,6 +1,10 @@
 package jwt
 
 import (
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -22,3 +26,168 @@ func TestJwtToken(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestKeyringToken(t *testing.T) {
+	for _, m := range []jwt.SigningMethod{jwt.SigningMethodRS256, jwt.SigningMethodES256, jwt.SigningMethodEdDSA} {
+		k1, err := GenerateKey("k1", m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ring := NewKeyring(k1)
+		token, err := GenerateTokenWithKeyring("app_1", ring, "a", Userinfo{Id: "1"}, nil, 5*time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k2, _ := GenerateKey("k2", m)
+		if err = ring.Rotate(k2, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.Marshal(ring.Jwks())
+		keys, err := ParseJwks(data)
+		if err != nil || len(keys) != 2 {
+			t.Fatal(m.Alg(), "jwks keys", len(keys), err)
+		}
+		if _, err = ValidateTokenWithKeyring("app_1", "a", token, NewKeyring(nil, keys...)); err != nil {
+			t.Fatal(m.Alg(), err)
+		}
+		ring.Remove("k1")
+		if _, err = ValidateTokenWithKeyring("app_1", "a", token, ring); err == nil {
+			t.Fatal(m.Alg(), "removed key validated")
+		}
+	}
+}
+
+func TestJwkEC(t *testing.T) {
+	k, err := GenerateKey("k1", jwt.SigningMethodES384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, _ := k.Jwk()
+
+	noAlg := jwk
+	noAlg.Alg = ""
+	if key, err1 := noAlg.Key(); err1 != nil || key.Method.Alg() != "ES384" {
+		t.Fatal("alg not resolved from the crv", err1)
+	}
+	mismatch := jwk
+	mismatch.Alg = "ES256"
+	if _, err = mismatch.Key(); err == nil {
+		t.Fatal("crv not match the alg accepted")
+	}
+	offCurve := jwk
+	offCurve.Y = offCurve.X
+	if _, err = offCurve.Key(); err == nil {
+		t.Fatal("point not on the curve accepted")
+	}
+}
+
+func TestJwkOKP(t *testing.T) {
+	k, err := GenerateKey("k1", jwt.SigningMethodEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, _ := k.Jwk()
+	if _, err = jwk.Key(); err != nil {
+		t.Fatal(err)
+	}
+	short := jwk
+	short.X = short.X[:len(short.X)-4]
+	if _, err = short.Key(); err == nil {
+		t.Fatal("short x accepted")
+	}
+	empty := jwk
+	empty.X = ""
+	if _, err = empty.Key(); err == nil {
+		t.Fatal("empty x accepted")
+	}
+}
+
+func TestKeyStores(t *testing.T) {
+	file, err := NewFileKeyStore(filepath.Join(t.TempDir(), "keys.json"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, store := range []KeyStore{NewMemoryKeyStore("test"), file} {
+		testKeyStore(t, store)
+	}
+	reloaded, err := NewFileKeyStore(file.path, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessions, _ := reloaded.GetSessions("app_1", "1"); len(sessions) != 1 {
+		t.Fatal("file sessions not reloaded", sessions)
+	}
+}
+
+func testKeyStore(t *testing.T, store KeyStore) {
+	s1, k1, err := NewSession(store, "app_1", "1", "phone", time.Hour, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ := GenerateSessionToken("app_1", s1, k1, "a", Userinfo{Id: "1"}, nil, 5*time.Minute)
+	if _, err = ValidateToken("app_1", "a", token, SessionKeyProvider(store, "app_1")); err != nil {
+		t.Fatal(err)
+	}
+	_, _, _ = NewSession(store, "app_1", "1", "web", time.Hour, 2)
+	_, _, _ = NewSession(store, "app_1", "1", "pad", time.Hour, 2)
+	if _, err = ValidateToken("app_1", "a", token, SessionKeyProvider(store, "app_1")); err == nil {
+		t.Fatal("session over max not revoked")
+	}
+	sessions, _ := ListSessions(store, "app_1", "1")
+	if len(sessions) != 2 {
+		t.Fatal("sessions", sessions)
+	}
+	_ = RevokeSession(store, "app_1", "1", sessions[0].Id)
+
+	r := NewRefresher(store, KeySigner(GenKey(), "a"), time.Minute, time.Hour)
+	p1, err := r.Issue("app_1", Userinfo{Id: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, _, err := r.Refresh("app_1", p1.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = r.Refresh("app_1", p1.RefreshToken); err != ErrRefreshTokenReused {
+		t.Fatal("reuse", err)
+	}
+	if _, _, err = r.Refresh("app_1", p2.RefreshToken); err != ErrRefreshTokenInvalid {
+		t.Fatal("family not revoked", err)
+	}
+}
+
+func TestFileKeyStorePersist(t *testing.T) {
+	file, err := NewFileKeyStore(filepath.Join(t.TempDir(), "keys.json"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saves := 0
+	var fail error
+	file.persist = func(items map[string]*memoryItem) error {
+		if fail != nil {
+			return fail
+		}
+		saves++
+		return file.save(items)
+	}
+	r := NewRefresher(file, KeySigner(GenKey(), "a"), time.Minute, time.Hour)
+	p1, _ := r.Issue("app_1", Userinfo{Id: "1"})
+	_, _, _ = NewSession(file, "app_1", "1", "d", time.Hour, 0)
+	saves = 0
+	_, _ = file.GetSessions("app_1", "1")
+	_ = file.ExpireUserJwtKey("app_1", "1", time.Hour)
+	_ = file.ExpireSessionKey("app_1", "1", "missing", time.Hour)
+	_ = file.DelRefreshFamily("app_1", "missing")
+	if saves != 0 {
+		t.Fatal("file written without change", saves)
+	}
+
+	fail = errors.New("disk full")
+	if _, _, err = r.Refresh("app_1", p1.RefreshToken); err == nil {
+		t.Fatal("persist failure not returned")
+	}
+	fail = nil
+	if _, _, err = r.Refresh("app_1", p1.RefreshToken); err != nil {
+		t.Fatal("failed rotation not rolled back", err)
+	}
+}
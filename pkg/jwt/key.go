@@ -0,0 +1,160 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Key a signing or verifying key identified by the kid header
+type Key struct {
+	Kid     string
+	Method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// NewKey return a signing key of the method, the private key is *rsa.PrivateKey for RS256, *ecdsa.PrivateKey for ES256,
+// ed25519.PrivateKey for EdDSA and []byte for HS256
+func NewKey(kid string, method jwt.SigningMethod, private interface{}) (*Key, error) {
+	k := &Key{Kid: kid, Method: method, private: private}
+	switch pk := private.(type) {
+	case []byte:
+		k.public = pk
+	case crypto.Signer:
+		k.public = pk.Public()
+	default:
+		return nil, jwtError("unsupported private key")
+	}
+	if err := k.check(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// NewPublicKey return a verify only key of the method, the public key is *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+func NewPublicKey(kid string, method jwt.SigningMethod, public interface{}) (*Key, error) {
+	k := &Key{Kid: kid, Method: method, public: public}
+	if err := k.check(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// GenerateKey generate a key of the method, RS256 of 2048 bits, ES256 of P-256, EdDSA of ed25519 and HS256
+func GenerateKey(kid string, method jwt.SigningMethod) (*Key, error) {
+	var private interface{}
+	var err error
+	switch method.(type) {
+	case *jwt.SigningMethodRSA:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case *jwt.SigningMethodECDSA:
+		private, err = ecdsa.GenerateKey(curveOf(method), rand.Reader)
+	case *jwt.SigningMethodEd25519:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case *jwt.SigningMethodHMAC:
+		private = GenKey()
+	default:
+		return nil, jwtError("unsupported signing method")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(kid, method, private)
+}
+
+// ParsePrivateKeyPem return a signing key of the method from the pem encoded private key
+func ParsePrivateKeyPem(kid string, method jwt.SigningMethod, pem []byte) (*Key, error) {
+	var private interface{}
+	var err error
+	switch method.(type) {
+	case *jwt.SigningMethodRSA:
+		private, err = jwt.ParseRSAPrivateKeyFromPEM(pem)
+	case *jwt.SigningMethodECDSA:
+		private, err = jwt.ParseECPrivateKeyFromPEM(pem)
+	case *jwt.SigningMethodEd25519:
+		private, err = jwt.ParseEdPrivateKeyFromPEM(pem)
+	default:
+		return nil, jwtError("unsupported signing method")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(kid, method, private)
+}
+
+// ParsePublicKeyPem return a verify only key of the method from the pem encoded public key
+func ParsePublicKeyPem(kid string, method jwt.SigningMethod, pem []byte) (*Key, error) {
+	var public interface{}
+	var err error
+	switch method.(type) {
+	case *jwt.SigningMethodRSA:
+		public, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+	case *jwt.SigningMethodECDSA:
+		public, err = jwt.ParseECPublicKeyFromPEM(pem)
+	case *jwt.SigningMethodEd25519:
+		public, err = jwt.ParseEdPublicKeyFromPEM(pem)
+	default:
+		return nil, jwtError("unsupported signing method")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewPublicKey(kid, method, public)
+}
+
+// CanSign return if the key has the private part
+func (k *Key) CanSign() bool {
+	return k.private != nil
+}
+
+// Public return the public key, the secret for HS256
+func (k *Key) Public() interface{} {
+	return k.public
+}
+
+// Symmetric return if the key is a HS256 secret, which is never published
+func (k *Key) Symmetric() bool {
+	_, ok := k.Method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// check the key types match the method
+func (k *Key) check() error {
+	if k.Kid == "" {
+		return jwtError("key id empty")
+	}
+	var ok bool
+	switch k.Method.(type) {
+	case *jwt.SigningMethodRSA:
+		_, ok = k.public.(*rsa.PublicKey)
+	case *jwt.SigningMethodECDSA:
+		var pub *ecdsa.PublicKey
+		if pub, ok = k.public.(*ecdsa.PublicKey); ok {
+			ok = pub.Curve == curveOf(k.Method)
+		}
+	case *jwt.SigningMethodEd25519:
+		_, ok = k.public.(ed25519.PublicKey)
+	case *jwt.SigningMethodHMAC:
+		_, ok = k.public.([]byte)
+	}
+	if !ok {
+		return jwtError("key type not match the signing method")
+	}
+	return nil
+}
+
+func curveOf(method jwt.SigningMethod) elliptic.Curve {
+	switch method.Alg() {
+	case "ES384":
+		return elliptic.P384()
+	case "ES512":
+		return elliptic.P521()
+	default:
+		return elliptic.P256()
+	}
+}
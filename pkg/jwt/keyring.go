@@ -0,0 +1,117 @@
+package jwt
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type ringKey struct {
+	key      *Key
+	retireAt time.Time // zero for not retiring
+}
+
+// Keyring the signing key and the verifying keys. Rotate a new signing key with an overlap window, the old one still verifies
+// and is published until the window passed, so the tokens signed before keep valid and the verifiers have time to refresh
+type Keyring struct {
+	mu      sync.RWMutex
+	signing *Key
+	keys    map[string]*ringKey
+	now     func() time.Time
+}
+
+// NewKeyring return a keyring signing by the signing key, nil signing for a verify only keyring, such as the keys of a jwks
+func NewKeyring(signing *Key, verify ...*Key) *Keyring {
+	r := &Keyring{keys: make(map[string]*ringKey), now: time.Now}
+	r.Add(verify...)
+	if signing != nil {
+		r.signing = signing
+		r.keys[signing.Kid] = &ringKey{key: signing}
+	}
+	return r
+}
+
+// Add the verifying keys, such as the next signing key published ahead of the rotation
+func (r *Keyring) Add(keys ...*Key) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range keys {
+		if k != nil {
+			r.keys[k.Kid] = &ringKey{key: k}
+		}
+	}
+}
+
+// Rotate sign by the next key, the current signing key is retired after the overlap
+func (r *Keyring) Rotate(next *Key, overlap time.Duration) error {
+	if next == nil || !next.CanSign() {
+		return jwtError("rotate key can not sign")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.signing != nil && r.signing.Kid != next.Kid {
+		if rk, ok := r.keys[r.signing.Kid]; ok {
+			rk.retireAt = r.now().Add(overlap)
+		}
+	}
+	r.signing = next
+	r.keys[next.Kid] = &ringKey{key: next}
+	r.gc()
+	return nil
+}
+
+// Remove the key immediately, the tokens signed by it are invalid then
+func (r *Keyring) Remove(kid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.keys, kid)
+	if r.signing != nil && r.signing.Kid == kid {
+		r.signing = nil
+	}
+}
+
+// Signing return the signing key, nil for a verify only keyring
+func (r *Keyring) Signing() *Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.signing
+}
+
+// Key return the verifying key of the kid, the retired keys are not returned
+func (r *Keyring) Key(kid string) (*Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rk, ok := r.keys[kid]
+	if !ok || r.retired(rk) {
+		return nil, false
+	}
+	return rk.key, true
+}
+
+// Keys return the verifying keys not retired, sorted by kid
+func (r *Keyring) Keys() []*Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var keys []*Key
+	for _, rk := range r.keys {
+		if !r.retired(rk) {
+			keys = append(keys, rk.key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Kid < keys[j].Kid
+	})
+	return keys
+}
+
+func (r *Keyring) retired(rk *ringKey) bool {
+	return !rk.retireAt.IsZero() && !r.now().Before(rk.retireAt)
+}
+
+func (r *Keyring) gc() {
+	for kid, rk := range r.keys {
+		if r.retired(rk) {
+			delete(r.keys, kid)
+		}
+	}
+}
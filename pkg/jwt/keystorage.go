@@ -12,55 +12,121 @@ func jwtStoreError(msg string, err error) error {
 	return utils.TitledError("jwt key storage error", msg, err)
 }
 
+// KeyStore the storage of the user jwt keys, the session keys and the refresh token families.
+// The ttl <= 0 means never expire, the get methods return empty for the missing or expired ones
+type KeyStore interface {
+	// GetUserJwtKey get the jwt key of the user
+	GetUserJwtKey(subject, id string) (string, error)
+	// SetUserJwtKey set the jwt key of the user
+	SetUserJwtKey(subject, id, key string, ttl time.Duration) error
+	// ExpireUserJwtKey lengthen the jwt key of the user
+	ExpireUserJwtKey(subject, id string, ttl time.Duration) error
+	// DelUserJwtKey delete the jwt key of the user
+	DelUserJwtKey(subject, id string) error
+
+	// SetSessionKey set the jwt key of the user session and add the session to the session index of the user
+	SetSessionKey(subject, id string, session Session, key string, ttl time.Duration) error
+	// AddSession set the jwt key of the new user session like SetSessionKey, and revoke the oldest sessions in the same atomic
+	// step to keep the sessions of the user within max, 0 for unlimited
+	AddSession(subject, id string, session Session, key string, ttl time.Duration, max int) error
+	// GetSessionKey get the jwt key of the user session
+	GetSessionKey(subject, id, sid string) (string, error)
+	// ExpireSessionKey lengthen the jwt key of the user session
+	ExpireSessionKey(subject, id, sid string, ttl time.Duration) error
+	// GetSessions get the active sessions of the user, the expired ones are removed from the index
+	GetSessions(subject, id string) ([]Session, error)
+	// DelSessionKeys delete the jwt keys of the user sessions, all the sessions if no sid given
+	DelSessionKeys(subject, id string, sids ...string) error
+
+	// SetRefreshFamily set the current refresh token hash and the userinfo of the refresh token family
+	SetRefreshFamily(subject, family, tokenHash string, userinfo []byte, ttl time.Duration) error
+	// RotateRefreshFamily swap the current refresh token hash of the family from old to next atomically, return the userinfo if
+	// swapped. The family is deleted if the current one is not old with RefreshReused, RefreshMissing if the family not exists
+	RotateRefreshFamily(subject, family, old, next string, ttl time.Duration) (RefreshState, []byte, error)
+	// DelRefreshFamily delete the refresh token family
+	DelRefreshFamily(subject, family string) error
+
+	// Ping check the storage, for the server readiness probe
+	Ping(ctx context.Context) error
+}
+
 var keyPrefix string
 
+// SetKeyPrefix set the key prefix of the package level functions
+//
+// Deprecated: use the prefix of NewRedisKeyStore
 func SetKeyPrefix(prefix string) {
 	keyPrefix = prefix
 }
 
+// defaultStore the redis key storage under the key prefix behind the package level functions
+func defaultStore(rds *redis.Client) *RedisKeyStore {
+	return NewRedisKeyStore(rds, keyPrefix)
+}
+
 // GetUserJwtKey get user jwt key
+//
+// Deprecated: use RedisKeyStore.GetUserJwtKey
 func GetUserJwtKey(rds *redis.Client, subject string, id string) (string, error) {
-	ck := tokenCacheKey(subject, id)
-	rs := rds.Get(context.Background(), ck)
-	if rs.Err() != nil {
-		if rs.Err() == redis.Nil {
-			return "", nil
-		}
-		return "", jwtStoreError("get user jwt key failed", rs.Err())
-	}
-	return rs.Val(), nil
+	return defaultStore(rds).GetUserJwtKey(subject, id)
 }
 
 // SetUserJwtKey set user jwt key
+//
+// Deprecated: use RedisKeyStore.SetUserJwtKey
 func SetUserJwtKey(rds *redis.Client, subject, id, key string, ttl time.Duration) error {
-	rs := rds.Set(context.Background(), tokenCacheKey(subject, id), key, ttl)
-	if rs.Err() != nil {
-		return jwtStoreError("set user jwt key failed", rs.Err())
-	}
-	return nil
+	return defaultStore(rds).SetUserJwtKey(subject, id, key, ttl)
 }
 
 // ExpireUserJwtKey lengthen user jwt key
+//
+// Deprecated: use RedisKeyStore.ExpireUserJwtKey
 func ExpireUserJwtKey(rds *redis.Client, subject, id string, ttl time.Duration) error {
-	rs := rds.Expire(context.Background(), tokenCacheKey(subject, id), ttl)
-	if rs.Err() != nil {
-		return jwtStoreError("expire user jwt key failed", rs.Err())
-	}
-	return nil
+	return defaultStore(rds).ExpireUserJwtKey(subject, id, ttl)
 }
 
 // DelUserJwtKey delete user jwt key
+//
+// Deprecated: use RedisKeyStore.DelUserJwtKey
 func DelUserJwtKey(rds *redis.Client, subject, id string) error {
-	rs := rds.Del(context.Background(), tokenCacheKey(subject, id))
-	if rs.Err() != nil {
-		return jwtStoreError("del user jwt key failed", rs.Err())
-	}
-	return rs.Err()
+	return defaultStore(rds).DelUserJwtKey(subject, id)
+}
+
+// PingKeyStorage return a checker of the key storage, for the server readiness probe
+//
+// Deprecated: use RedisKeyStore.Ping
+func PingKeyStorage(rds *redis.Client) func(ctx context.Context) error {
+	return defaultStore(rds).Ping
 }
 
-func tokenCacheKey(subject, id string) string {
-	if keyPrefix != "" {
-		return strings.Join([]string{keyPrefix, `tokens`, subject, id}, ":")
+// storeKeys the storage keys under the prefix, the parts are joined by ':'
+type storeKeys string
+
+func (p storeKeys) join(parts ...string) string {
+	if p != "" {
+		parts = append([]string{string(p)}, parts...)
 	}
-	return strings.Join([]string{`tokens`, subject, id}, ":")
+	return strings.Join(parts, ":")
+}
+
+func (p storeKeys) user(subject, id string) string {
+	return p.join(`tokens`, subject, id)
+}
+
+// session the session keys and the session index of a user share the hash tag {subject:id}, so they are in the same slot of
+// a redis cluster for the session scripts and transactions, the prefix should not contain braces
+func (p storeKeys) session(subject, id, sid string) string {
+	return p.join(`tokens`, userTag(subject, id), sid)
+}
+
+func (p storeKeys) sessionIndex(subject, id string) string {
+	return p.join(`sessions`, userTag(subject, id))
+}
+
+func userTag(subject, id string) string {
+	return "{" + subject + ":" + id + "}"
+}
+
+func (p storeKeys) refresh(subject, family string) string {
+	return p.join(`refresh`, subject, family)
 }
@@ -0,0 +1,318 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryItem a key of the memory storage, Fields for the session index and the refresh family
+type memoryItem struct {
+	Value   string            `json:"value,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Expires time.Time         `json:"expires"` // zero for never expire
+}
+
+// MemoryKeyStore in-process key storage, the keys are not shared between instances, for the tests and the single node deployments
+type MemoryKeyStore struct {
+	mu      sync.Mutex
+	keys    storeKeys
+	items   map[string]*memoryItem
+	lastGc  time.Time
+	now     func() time.Time
+	persist func(items map[string]*memoryItem) error // called locked after the changes, set by the file storage
+}
+
+// NewMemoryKeyStore return a memory key storage, the keys are prefixed by prefix and ':', no prefix if empty
+func NewMemoryKeyStore(prefix string) *MemoryKeyStore {
+	return &MemoryKeyStore{
+		keys:   storeKeys(prefix),
+		items:  make(map[string]*memoryItem),
+		lastGc: time.Now(),
+		now:    time.Now,
+	}
+}
+
+func (s *MemoryKeyStore) GetUserJwtKey(subject, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if it := s.get(s.keys.user(subject, id)); it != nil {
+		return it.Value, nil
+	}
+	return "", nil
+}
+
+func (s *MemoryKeyStore) SetUserJwtKey(subject, id, key string, ttl time.Duration) error {
+	return s.update(func() bool {
+		s.items[s.keys.user(subject, id)] = &memoryItem{Value: key, Expires: s.expires(ttl)}
+		return true
+	})
+}
+
+func (s *MemoryKeyStore) ExpireUserJwtKey(subject, id string, ttl time.Duration) error {
+	return s.update(func() bool {
+		it := s.get(s.keys.user(subject, id))
+		if it == nil {
+			return false
+		}
+		it.Expires = s.expires(ttl)
+		return true
+	})
+}
+
+func (s *MemoryKeyStore) DelUserJwtKey(subject, id string) error {
+	return s.update(func() bool {
+		return s.del(s.keys.user(subject, id))
+	})
+}
+
+func (s *MemoryKeyStore) SetSessionKey(subject, id string, session Session, key string, ttl time.Duration) error {
+	return s.AddSession(subject, id, session, key, ttl, 0)
+}
+
+func (s *MemoryKeyStore) AddSession(subject, id string, session Session, key string, ttl time.Duration, max int) error {
+	info, err := json.Marshal(session)
+	if err != nil {
+		return jwtStoreError("set session key failed", err)
+	}
+	return s.update(func() bool {
+		if max > 0 {
+			sessions := s.sessions(subject, id)
+			sort.Slice(sessions, func(i, j int) bool {
+				return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+			})
+			if n := len(sessions) - max + 1; n > 0 {
+				var sids []string
+				for _, old := range sessions[:n] {
+					sids = append(sids, old.Id)
+				}
+				s.delSessions(subject, id, sids)
+			}
+		}
+		s.items[s.keys.session(subject, id, session.Id)] = &memoryItem{Value: key, Expires: s.expires(ttl)}
+		ik := s.keys.sessionIndex(subject, id)
+		index := s.get(ik)
+		if index == nil {
+			index = &memoryItem{Fields: make(map[string]string), Expires: s.expires(ttl)}
+			s.items[ik] = index
+		}
+		index.Fields[session.Id] = string(info)
+		s.extend(index, ttl)
+		return true
+	})
+}
+
+func (s *MemoryKeyStore) GetSessionKey(subject, id, sid string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if it := s.get(s.keys.session(subject, id, sid)); it != nil {
+		return it.Value, nil
+	}
+	return "", nil
+}
+
+func (s *MemoryKeyStore) ExpireSessionKey(subject, id, sid string, ttl time.Duration) error {
+	return s.update(func() bool {
+		it := s.get(s.keys.session(subject, id, sid))
+		if it == nil {
+			return false
+		}
+		it.Expires = s.expires(ttl)
+		if index := s.get(s.keys.sessionIndex(subject, id)); index != nil {
+			s.extend(index, ttl)
+		}
+		return true
+	})
+}
+
+func (s *MemoryKeyStore) GetSessions(subject, id string) ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions(subject, id), nil
+}
+
+func (s *MemoryKeyStore) DelSessionKeys(subject, id string, sids ...string) error {
+	return s.update(func() bool {
+		return s.delSessions(subject, id, sids)
+	})
+}
+
+// sessions return the active sessions of the user and remove the expired ones from the index, called locked
+func (s *MemoryKeyStore) sessions(subject, id string) []Session {
+	ik := s.keys.sessionIndex(subject, id)
+	index := s.get(ik)
+	if index == nil {
+		return nil
+	}
+	var sessions []Session
+	for sid, info := range index.Fields {
+		var session Session
+		if s.get(s.keys.session(subject, id, sid)) == nil || json.Unmarshal([]byte(info), &session) != nil {
+			delete(index.Fields, sid)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	if len(index.Fields) == 0 {
+		delete(s.items, ik)
+	}
+	return sessions
+}
+
+// delSessions delete the user sessions, all the sessions if no sid given, return if any deleted, called locked
+func (s *MemoryKeyStore) delSessions(subject, id string, sids []string) (deleted bool) {
+	ik := s.keys.sessionIndex(subject, id)
+	index := s.get(ik)
+	if len(sids) == 0 && index != nil {
+		for sid := range index.Fields {
+			sids = append(sids, sid)
+		}
+	}
+	for _, sid := range sids {
+		if s.del(s.keys.session(subject, id, sid)) {
+			deleted = true
+		}
+		if index != nil {
+			if _, ok := index.Fields[sid]; ok {
+				delete(index.Fields, sid)
+				deleted = true
+			}
+		}
+	}
+	if index != nil && len(index.Fields) == 0 {
+		delete(s.items, ik)
+	}
+	return
+}
+
+func (s *MemoryKeyStore) SetRefreshFamily(subject, family, tokenHash string, userinfo []byte, ttl time.Duration) error {
+	return s.update(func() bool {
+		s.items[s.keys.refresh(subject, family)] = &memoryItem{
+			Fields:  map[string]string{"token": tokenHash, "userinfo": string(userinfo)},
+			Expires: s.expires(ttl),
+		}
+		return true
+	})
+}
+
+func (s *MemoryKeyStore) RotateRefreshFamily(subject, family, old, next string, ttl time.Duration) (state RefreshState, userinfo []byte, err error) {
+	err = s.update(func() bool {
+		ck := s.keys.refresh(subject, family)
+		it := s.get(ck)
+		if it == nil {
+			state = RefreshMissing
+			return false
+		}
+		if it.Fields["token"] != old {
+			delete(s.items, ck)
+			state = RefreshReused
+			return true
+		}
+		it.Fields["token"] = next
+		it.Expires = s.expires(ttl)
+		state, userinfo = RefreshRotated, []byte(it.Fields["userinfo"])
+		return true
+	})
+	return
+}
+
+func (s *MemoryKeyStore) DelRefreshFamily(subject, family string) error {
+	return s.update(func() bool {
+		return s.del(s.keys.refresh(subject, family))
+	})
+}
+
+func (s *MemoryKeyStore) Ping(_ context.Context) error {
+	return nil
+}
+
+// update run the change locked, the change return false if nothing changed. The changes are persisted if set, rolled back if
+// the persistence failed, so the memory never holds what the file does not
+func (s *MemoryKeyStore) update(change func() bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gc()
+	if s.persist == nil {
+		change()
+		return nil
+	}
+	snapshot := cloneItems(s.items)
+	if !change() {
+		return nil
+	}
+	if err := s.persist(s.items); err != nil {
+		s.items = snapshot
+		return jwtStoreError("persist keys failed", err)
+	}
+	return nil
+}
+
+// del delete the item, return false if missing or expired
+func (s *MemoryKeyStore) del(key string) bool {
+	if s.get(key) == nil {
+		return false
+	}
+	delete(s.items, key)
+	return true
+}
+
+// get return the item not expired, nil if missing or expired
+func (s *MemoryKeyStore) get(key string) *memoryItem {
+	it, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	if !it.Expires.IsZero() && !s.now().Before(it.Expires) {
+		delete(s.items, key)
+		return nil
+	}
+	return it
+}
+
+func (s *MemoryKeyStore) expires(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return s.now().Add(ttl)
+}
+
+// extend the item to live at least the ttl
+func (s *MemoryKeyStore) extend(it *memoryItem, ttl time.Duration) {
+	if it.Expires.IsZero() {
+		return
+	}
+	if exp := s.expires(ttl); exp.IsZero() || exp.After(it.Expires) {
+		it.Expires = exp
+	}
+}
+
+// gc remove the expired items once a minute
+func (s *MemoryKeyStore) gc() {
+	now := s.now()
+	if now.Sub(s.lastGc) < time.Minute {
+		return
+	}
+	s.lastGc = now
+	for k, it := range s.items {
+		if !it.Expires.IsZero() && !now.Before(it.Expires) {
+			delete(s.items, k)
+		}
+	}
+}
+
+func cloneItems(items map[string]*memoryItem) map[string]*memoryItem {
+	clone := make(map[string]*memoryItem, len(items))
+	for k, it := range items {
+		c := *it
+		if it.Fields != nil {
+			c.Fields = make(map[string]string, len(it.Fields))
+			for f, v := range it.Fields {
+				c.Fields[f] = v
+			}
+		}
+		clone[k] = &c
+	}
+	return clone
+}
@@ -0,0 +1,317 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// RedisKeyStore key storage shared between instances by redis
+type RedisKeyStore struct {
+	rds  *redis.Client
+	keys storeKeys
+}
+
+// NewRedisKeyStore return a redis key storage, the keys are prefixed by prefix and ':', no prefix if empty
+func NewRedisKeyStore(rds *redis.Client, prefix string) *RedisKeyStore {
+	return &RedisKeyStore{rds: rds, keys: storeKeys(prefix)}
+}
+
+// GetUserJwtKey get user jwt key
+func (s *RedisKeyStore) GetUserJwtKey(subject string, id string) (string, error) {
+	ck := s.keys.user(subject, id)
+	rs := s.rds.Get(context.Background(), ck)
+	if rs.Err() != nil {
+		if rs.Err() == redis.Nil {
+			return "", nil
+		}
+		return "", jwtStoreError("get user jwt key failed", rs.Err())
+	}
+	return rs.Val(), nil
+}
+
+// SetUserJwtKey set user jwt key, never expire if ttl <= 0
+func (s *RedisKeyStore) SetUserJwtKey(subject, id, key string, ttl time.Duration) error {
+	rs := s.rds.Set(context.Background(), s.keys.user(subject, id), key, setTtl(ttl))
+	if rs.Err() != nil {
+		return jwtStoreError("set user jwt key failed", rs.Err())
+	}
+	return nil
+}
+
+// ExpireUserJwtKey lengthen user jwt key
+func (s *RedisKeyStore) ExpireUserJwtKey(subject, id string, ttl time.Duration) error {
+	if err := expire(s.rds, s.keys.user(subject, id), ttl); err != nil {
+		return jwtStoreError("expire user jwt key failed", err)
+	}
+	return nil
+}
+
+// DelUserJwtKey delete user jwt key
+func (s *RedisKeyStore) DelUserJwtKey(subject, id string) error {
+	rs := s.rds.Del(context.Background(), s.keys.user(subject, id))
+	if rs.Err() != nil {
+		return jwtStoreError("del user jwt key failed", rs.Err())
+	}
+	return rs.Err()
+}
+
+// SetSessionKey set the jwt key of the user session and add the session to the session index of the user
+func (s *RedisKeyStore) SetSessionKey(subject, id string, session Session, key string, ttl time.Duration) error {
+	return s.AddSession(subject, id, session, key, ttl, 0)
+}
+
+// indexedSession the session info in the session index, ts orders the sessions in the script
+type indexedSession struct {
+	Session
+	Ts int64 `json:"ts"`
+}
+
+// sessionAdd revoke the oldest sessions in the index to keep max - 1 if max > 0, the expired ones are removed, then set the
+// session key and add it to the index, the index lives as long as the longest session. The session keys of the index are built
+// from the prefix ARGV[1], they share the hash tag of KEYS so the script works on a redis cluster
+var sessionAdd = redis.NewScript(`
+local max = tonumber(ARGV[6])
+if max > 0 then
+	local index = redis.call('HGETALL', KEYS[1])
+	local live = {}
+	for i = 1, #index, 2 do
+		local sid = index[i]
+		local ok, info = pcall(cjson.decode, index[i + 1])
+		if ok and type(info) == 'table' and redis.call('EXISTS', ARGV[1] .. sid) == 1 then
+			table.insert(live, {sid = sid, ts = tonumber(info.ts) or 0})
+		else
+			redis.call('HDEL', KEYS[1], sid)
+		end
+	end
+	table.sort(live, function(a, b)
+		if a.ts ~= b.ts then
+			return a.ts < b.ts
+		end
+		return a.sid < b.sid
+	end)
+	for i = 1, #live - max + 1 do
+		redis.call('DEL', ARGV[1] .. live[i].sid)
+		redis.call('HDEL', KEYS[1], live[i].sid)
+	end
+end
+local ttl = tonumber(ARGV[5])
+local cur = redis.call('PTTL', KEYS[1])
+if ttl > 0 then
+	redis.call('SET', KEYS[2], ARGV[3], 'PX', ttl)
+else
+	redis.call('SET', KEYS[2], ARGV[3])
+end
+redis.call('HSET', KEYS[1], ARGV[2], ARGV[4])
+if ttl <= 0 or cur == -1 then
+	redis.call('PERSIST', KEYS[1])
+elseif cur < ttl then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// AddSession set the jwt key of the new user session and revoke the oldest sessions to keep the sessions within max in a script
+func (s *RedisKeyStore) AddSession(subject, id string, session Session, key string, ttl time.Duration, max int) error {
+	info, err := json.Marshal(indexedSession{Session: session, Ts: session.CreatedAt.UnixMicro()})
+	if err != nil {
+		return jwtStoreError("set session key failed", err)
+	}
+	keys := []string{s.keys.sessionIndex(subject, id), s.keys.session(subject, id, session.Id)}
+	err = sessionAdd.Run(context.Background(), s.rds, keys, s.keys.session(subject, id, ""), session.Id, key, info, ttl.Milliseconds(), max).Err()
+	if err != nil {
+		return jwtStoreError("set session key failed", err)
+	}
+	return nil
+}
+
+// GetSessionKey get the jwt key of the user session, empty if revoked or expired
+func (s *RedisKeyStore) GetSessionKey(subject, id, sid string) (string, error) {
+	rs := s.rds.Get(context.Background(), s.keys.session(subject, id, sid))
+	if rs.Err() != nil {
+		if rs.Err() == redis.Nil {
+			return "", nil
+		}
+		return "", jwtStoreError("get session key failed", rs.Err())
+	}
+	return rs.Val(), nil
+}
+
+// ExpireSessionKey lengthen the jwt key of the user session
+func (s *RedisKeyStore) ExpireSessionKey(subject, id, sid string, ttl time.Duration) error {
+	ik := s.keys.sessionIndex(subject, id)
+	indexTtl, err := s.indexTtl(ik, ttl)
+	if err != nil {
+		return jwtStoreError("expire session key failed", err)
+	}
+	_, err = s.rds.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		if err := expire(pipe, s.keys.session(subject, id, sid), ttl); err != nil {
+			return err
+		}
+		return expire(pipe, ik, indexTtl)
+	})
+	if err != nil {
+		return jwtStoreError("expire session key failed", err)
+	}
+	return nil
+}
+
+// GetSessions get the active sessions of the user, the expired ones are removed from the index
+func (s *RedisKeyStore) GetSessions(subject, id string) ([]Session, error) {
+	ik := s.keys.sessionIndex(subject, id)
+	index, err := s.rds.HGetAll(context.Background(), ik).Result()
+	if err != nil {
+		return nil, jwtStoreError("get sessions failed", err)
+	}
+	if len(index) == 0 {
+		return nil, nil
+	}
+	sids := make([]string, 0, len(index))
+	keys := make([]string, 0, len(index))
+	for sid := range index {
+		sids = append(sids, sid)
+		keys = append(keys, s.keys.session(subject, id, sid))
+	}
+	vals, err := s.rds.MGet(context.Background(), keys...).Result()
+	if err != nil {
+		return nil, jwtStoreError("get sessions failed", err)
+	}
+	var sessions []Session
+	var expired []string
+	for i, sid := range sids {
+		var session Session
+		if vals[i] == nil || json.Unmarshal([]byte(index[sid]), &session) != nil {
+			expired = append(expired, sid)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	if len(expired) > 0 {
+		if err = s.rds.HDel(context.Background(), ik, expired...).Err(); err != nil {
+			return nil, jwtStoreError("get sessions failed", err)
+		}
+	}
+	return sessions, nil
+}
+
+// DelSessionKeys delete the jwt keys of the user sessions, all the sessions if no sid given
+func (s *RedisKeyStore) DelSessionKeys(subject, id string, sids ...string) error {
+	ik := s.keys.sessionIndex(subject, id)
+	if len(sids) == 0 {
+		var err error
+		if sids, err = s.rds.HKeys(context.Background(), ik).Result(); err != nil {
+			return jwtStoreError("del session keys failed", err)
+		}
+	}
+	keys := []string{}
+	for _, sid := range sids {
+		keys = append(keys, s.keys.session(subject, id, sid))
+	}
+	_, err := s.rds.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		if len(keys) > 0 {
+			pipe.Del(context.Background(), keys...)
+			pipe.HDel(context.Background(), ik, sids...)
+		}
+		return nil
+	})
+	if err != nil {
+		return jwtStoreError("del session keys failed", err)
+	}
+	return nil
+}
+
+// SetRefreshFamily set the current refresh token hash and the userinfo of the refresh token family
+func (s *RedisKeyStore) SetRefreshFamily(subject, family, tokenHash string, userinfo []byte, ttl time.Duration) error {
+	ck := s.keys.refresh(subject, family)
+	_, err := s.rds.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		pipe.HSet(context.Background(), ck, "token", tokenHash, "userinfo", userinfo)
+		return expire(pipe, ck, ttl)
+	})
+	if err != nil {
+		return jwtStoreError("set refresh family failed", err)
+	}
+	return nil
+}
+
+// refreshRotate swap the current token hash of the family if matched and return the userinfo,
+// delete the family if not matched as the old token reused
+var refreshRotate = redis.NewScript(`
+local cur = redis.call('HGET', KEYS[1], 'token')
+if not cur then
+	return {-1, ''}
+end
+if cur ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return {0, ''}
+end
+redis.call('HSET', KEYS[1], 'token', ARGV[2])
+if tonumber(ARGV[3]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+else
+	redis.call('PERSIST', KEYS[1])
+end
+return {1, redis.call('HGET', KEYS[1], 'userinfo')}
+`)
+
+// RotateRefreshFamily swap the current refresh token hash of the family from old to next, return the userinfo if swapped.
+// The family is deleted if the current one is not old, RefreshReused returned, RefreshMissing returned if the family not exists
+func (s *RedisKeyStore) RotateRefreshFamily(subject, family, old, next string, ttl time.Duration) (RefreshState, []byte, error) {
+	rs, err := refreshRotate.Run(context.Background(), s.rds, []string{s.keys.refresh(subject, family)}, old, next, ttl.Milliseconds()).Slice()
+	if err != nil {
+		return RefreshMissing, nil, jwtStoreError("rotate refresh family failed", err)
+	}
+	if len(rs) != 2 {
+		return RefreshMissing, nil, jwtStoreError("rotate refresh family failed", errors.New("unexpected script result"))
+	}
+	state, _ := rs[0].(int64)
+	userinfo, _ := rs[1].(string)
+	return RefreshState(state), []byte(userinfo), nil
+}
+
+// DelRefreshFamily delete the refresh token family, the refresh tokens of it are invalid then
+func (s *RedisKeyStore) DelRefreshFamily(subject, family string) error {
+	if err := s.rds.Del(context.Background(), s.keys.refresh(subject, family)).Err(); err != nil {
+		return jwtStoreError("del refresh family failed", err)
+	}
+	return nil
+}
+
+func (s *RedisKeyStore) Ping(ctx context.Context) error {
+	if err := s.rds.Ping(ctx).Err(); err != nil {
+		return jwtStoreError("ping key storage failed", err)
+	}
+	return nil
+}
+
+// indexTtl the session index lives as long as the longest session, 0 for never expire
+func (s *RedisKeyStore) indexTtl(ik string, ttl time.Duration) (time.Duration, error) {
+	cur, err := s.rds.PTTL(context.Background(), ik).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 || cur == -1 {
+		return 0, nil
+	}
+	if cur > ttl {
+		return cur, nil
+	}
+	return ttl, nil
+}
+
+// setTtl the expiration of SET, 0 for never expire, the negative ones are not passed as go-redis takes -1 as KEEPTTL
+func setTtl(ttl time.Duration) time.Duration {
+	if ttl > 0 {
+		return ttl
+	}
+	return 0
+}
+
+// expire the key after the ttl, never expire if ttl <= 0
+func expire(c redis.Cmdable, key string, ttl time.Duration) error {
+	if ttl > 0 {
+		return c.PExpire(context.Background(), key, ttl).Err()
+	}
+	return c.Persist(context.Background(), key).Err()
+}
@@ -0,0 +1,51 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"testing"
+	"time"
+)
+
+// captureHook record the args of the commands and abort them, no redis server needed
+type captureHook struct {
+	args []interface{}
+}
+
+func (h *captureHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	h.args = cmd.Args()
+	return ctx, errors.New("captured")
+}
+
+func (h *captureHook) AfterProcess(context.Context, redis.Cmder) error {
+	return nil
+}
+
+func (h *captureHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, errors.New("captured")
+}
+
+func (h *captureHook) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
+
+func TestRedisSetUserJwtKeyTtl(t *testing.T) {
+	rds := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	defer rds.Close()
+	hook := &captureHook{}
+	rds.AddHook(hook)
+	store := NewRedisKeyStore(rds, "api")
+	for ttl, want := range map[time.Duration]string{
+		-1:          "[set api:tokens:app_1:1 k]",
+		-time.Hour:  "[set api:tokens:app_1:1 k]",
+		0:           "[set api:tokens:app_1:1 k]",
+		time.Minute: "[set api:tokens:app_1:1 k ex 60]",
+	} {
+		_ = store.SetUserJwtKey("app_1", "1", "k", ttl)
+		if got := fmt.Sprint(hook.args); got != want {
+			t.Fatal("ttl "+ttl.String()+" set as", got)
+		}
+	}
+}
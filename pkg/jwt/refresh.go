@@ -0,0 +1,170 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// RefreshState the result of a refresh family rotation
+type RefreshState int
+
+const (
+	RefreshMissing RefreshState = -1 // family revoked or expired
+	RefreshReused  RefreshState = 0  // token already used, family revoked
+	RefreshRotated RefreshState = 1
+)
+
+var (
+	ErrRefreshTokenInvalid = jwtError("refresh token invalid, revoked or expired")
+	ErrRefreshTokenReused  = jwtError("refresh token reused, token family revoked")
+)
+
+// Signer sign the access token of the userinfo for the subject
+type Signer func(subject string, userinfo Userinfo, ttl time.Duration) (string, error)
+
+// KeySigner sign the access tokens by the HS256 key
+func KeySigner(key []byte, issuer string) Signer {
+	return func(subject string, userinfo Userinfo, ttl time.Duration) (string, error) {
+		return GenerateToken(subject, key, issuer, userinfo, nil, ttl)
+	}
+}
+
+// KeyringSigner sign the access tokens by the signing key of the keyring
+func KeyringSigner(ring *Keyring, issuer string) Signer {
+	return func(subject string, userinfo Userinfo, ttl time.Duration) (string, error) {
+		return GenerateTokenWithKeyring(subject, ring, issuer, userinfo, nil, ttl)
+	}
+}
+
+// TokenPair a short-lived access token and its refresh token
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // seconds of the access token
+}
+
+// Refresher issue the token pairs and exchange the refresh tokens for new pairs. The refresh tokens issued by an Issue and the
+// following exchanges are a family, only the latest one of the family is valid, each exchange rotates it. A used refresh token
+// showing up again means it is leaked, the whole family is revoked then
+type Refresher struct {
+	store      KeyStore
+	signer     Signer
+	accessTtl  time.Duration
+	refreshTtl time.Duration
+}
+
+// NewRefresher return a refresher signing the access tokens by the signer, the refresh token of a family expires if not
+// exchanged within the refresh ttl
+func NewRefresher(store KeyStore, signer Signer, accessTtl, refreshTtl time.Duration) *Refresher {
+	return &Refresher{
+		store:      store,
+		signer:     signer,
+		accessTtl:  accessTtl,
+		refreshTtl: refreshTtl,
+	}
+}
+
+// Issue return a token pair of a new refresh token family, such as at login
+func (r *Refresher) Issue(subject string, userinfo Userinfo) (TokenPair, error) {
+	family, err := randToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refreshToken, err := newRefreshToken(family)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	info, err := json.Marshal(userinfo)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if err = r.store.SetRefreshFamily(subject, family, hashToken(refreshToken), info, r.refreshTtl); err != nil {
+		return TokenPair{}, err
+	}
+	return r.pair(subject, userinfo, refreshToken)
+}
+
+// Refresh exchange the refresh token for a new pair, ErrRefreshTokenReused if the token was used and the family revoked
+func (r *Refresher) Refresh(subject, refreshToken string) (TokenPair, Userinfo, error) {
+	family, ok := refreshFamily(refreshToken)
+	if !ok {
+		return TokenPair{}, Userinfo{}, ErrRefreshTokenInvalid
+	}
+	next, err := newRefreshToken(family)
+	if err != nil {
+		return TokenPair{}, Userinfo{}, err
+	}
+	state, info, err := r.store.RotateRefreshFamily(subject, family, hashToken(refreshToken), hashToken(next), r.refreshTtl)
+	if err != nil {
+		return TokenPair{}, Userinfo{}, err
+	}
+	switch state {
+	case RefreshRotated:
+	case RefreshReused:
+		return TokenPair{}, Userinfo{}, ErrRefreshTokenReused
+	default:
+		return TokenPair{}, Userinfo{}, ErrRefreshTokenInvalid
+	}
+	var userinfo Userinfo
+	if err = json.Unmarshal(info, &userinfo); err != nil {
+		return TokenPair{}, Userinfo{}, jwtError("refresh family userinfo invalid")
+	}
+	pair, err := r.pair(subject, userinfo, next)
+	return pair, userinfo, err
+}
+
+// Revoke the family of the refresh token, such as at logout, the issued access tokens keep valid until expired
+func (r *Refresher) Revoke(subject, refreshToken string) error {
+	family, ok := refreshFamily(refreshToken)
+	if !ok {
+		return ErrRefreshTokenInvalid
+	}
+	return r.store.DelRefreshFamily(subject, family)
+}
+
+func (r *Refresher) pair(subject string, userinfo Userinfo, refreshToken string) (TokenPair, error) {
+	accessToken, err := r.signer(subject, userinfo, r.accessTtl)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(r.accessTtl / time.Second),
+	}, nil
+}
+
+// newRefreshToken return a refresh token of the family, {family}.{random}
+func newRefreshToken(family string) (string, error) {
+	secret, err := randToken()
+	if err != nil {
+		return "", err
+	}
+	return family + "." + secret, nil
+}
+
+func refreshFamily(refreshToken string) (string, bool) {
+	family, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || family == "" || secret == "" {
+		return "", false
+	}
+	return family, true
+}
+
+func randToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b64.EncodeToString(b), nil
+}
+
+// hashToken the refresh tokens are stored hashed
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,101 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshRotation(t *testing.T) {
+	key := GenKey()
+	r := NewRefresher(NewMemoryKeyStore("test"), KeySigner(key, "a"), time.Minute, time.Hour)
+	p1, err := r.Issue("app_1", Userinfo{Id: "1", Name: "n1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p1.ExpiresIn != 60 || p1.RefreshToken == "" {
+		t.Fatal("unexpected pair", p1)
+	}
+	if _, err = ValidateToken("app_1", "a", p1.AccessToken, func(*ZyClaims) ([]byte, error) { return key, nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	p2, userinfo, err := r.Refresh("app_1", p1.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userinfo.Id != "1" || userinfo.Name != "n1" {
+		t.Fatal("userinfo not kept", userinfo)
+	}
+	f1, _ := refreshFamily(p1.RefreshToken)
+	f2, _ := refreshFamily(p2.RefreshToken)
+	if p2.RefreshToken == p1.RefreshToken || f1 != f2 {
+		t.Fatal("refresh token not rotated in the family")
+	}
+	if _, _, err = r.Refresh("app_1", p2.RefreshToken); err != nil {
+		t.Fatal("rotated token not valid", err)
+	}
+}
+
+func TestRefreshReuse(t *testing.T) {
+	r := NewRefresher(NewMemoryKeyStore("test"), KeySigner(GenKey(), "a"), time.Minute, time.Hour)
+	p1, _ := r.Issue("app_1", Userinfo{Id: "1"})
+	p2, _, err := r.Refresh("app_1", p1.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = r.Refresh("app_1", p1.RefreshToken); err != ErrRefreshTokenReused {
+		t.Fatal("reuse not detected", err)
+	}
+	if _, _, err = r.Refresh("app_1", p2.RefreshToken); err != ErrRefreshTokenInvalid {
+		t.Fatal("family not revoked after reuse", err)
+	}
+
+	// another family is not affected
+	p3, _ := r.Issue("app_1", Userinfo{Id: "1"})
+	if _, _, err = r.Refresh("app_1", p3.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = r.Refresh("app_2", p3.RefreshToken); err != ErrRefreshTokenInvalid {
+		t.Fatal("family of another subject refreshed", err)
+	}
+}
+
+func TestRefreshRevokeAndExpire(t *testing.T) {
+	store := NewMemoryKeyStore("test")
+	now := time.Now()
+	store.now = func() time.Time {
+		return now
+	}
+	r := NewRefresher(store, KeySigner(GenKey(), "a"), time.Minute, time.Hour)
+
+	for _, token := range []string{"", "family", "family.", ".secret"} {
+		if _, _, err := r.Refresh("app_1", token); err != ErrRefreshTokenInvalid {
+			t.Fatal("malformed token accepted: " + token)
+		}
+	}
+
+	p1, _ := r.Issue("app_1", Userinfo{Id: "1"})
+	if err := r.Revoke("app_1", p1.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.Refresh("app_1", p1.RefreshToken); err != ErrRefreshTokenInvalid {
+		t.Fatal("revoked family refreshed", err)
+	}
+
+	p2, _ := r.Issue("app_1", Userinfo{Id: "1"})
+	now = now.Add(59 * time.Minute)
+	p3, _, err := r.Refresh("app_1", p2.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each rotation renews the refresh ttl
+	now = now.Add(59 * time.Minute)
+	if _, _, err = r.Refresh("app_1", p3.RefreshToken); err != nil {
+		t.Fatal("rotated family expired", err)
+	}
+	p4, _ := r.Issue("app_1", Userinfo{Id: "1"})
+	now = now.Add(time.Hour)
+	if _, _, err = r.Refresh("app_1", p4.RefreshToken); err != ErrRefreshTokenInvalid {
+		t.Fatal("expired family refreshed", err)
+	}
+}
@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+	"sort"
+	"time"
+)
+
+// Session a login session of a user on a device or client, signed by its own jwt key
+type Session struct {
+	Id        string    `json:"id"`
+	Device    string    `json:"device"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewSession create a session of the user on the device and return the jwt key of it. The oldest sessions are revoked atomically
+// with the creation to keep the sessions of the user within max, 0 for unlimited
+func NewSession(store KeyStore, subject, id, device string, ttl time.Duration, max int) (Session, []byte, error) {
+	sid, err := randToken()
+	if err != nil {
+		return Session{}, nil, err
+	}
+	session := Session{Id: sid, Device: device, CreatedAt: time.Now()}
+	key := GenKey()
+	if err = store.AddSession(subject, id, session, string(key), ttl, max); err != nil {
+		return Session{}, nil, err
+	}
+	return session, key, nil
+}
+
+// ListSessions return the active sessions of the user, the oldest first
+func ListSessions(store KeyStore, subject, id string) ([]Session, error) {
+	sessions, err := store.GetSessions(subject, id)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// RevokeSession revoke the session of the user, the tokens of it are invalid immediately
+func RevokeSession(store KeyStore, subject, id, sid string) error {
+	return store.DelSessionKeys(subject, id, sid)
+}
+
+// RevokeSessions revoke all the sessions of the user, such as logout everywhere
+func RevokeSessions(store KeyStore, subject, id string) error {
+	return store.DelSessionKeys(subject, id)
+}
+
+// GenerateSessionToken generate a token of the session signed by the session key, the session id is set to the sid claim and the kid header
+func GenerateSessionToken(subject string, session Session, key []byte, issuer string, userinfo Userinfo, notBefore *jwt.NumericDate, ttl time.Duration) (string, error) {
+	c := newClaims(subject, issuer, userinfo, notBefore, ttl)
+	c.Sid = session.Id
+	token := jwt.NewWithClaims(SignMethod, c)
+	token.Header["kid"] = session.Id
+
+	return token.SignedString(key)
+}
+
+// SessionKeyProvider return the key provider of ValidateToken reading the session key of the sid claim,
+// the user key for the tokens without a session
+func SessionKeyProvider(store KeyStore, subject string) func(claims *ZyClaims) ([]byte, error) {
+	return func(claims *ZyClaims) ([]byte, error) {
+		var key string
+		var err error
+		if claims.Sid != "" {
+			key, err = store.GetSessionKey(subject, claims.Userinfo.Id, claims.Sid)
+		} else {
+			key, err = store.GetUserJwtKey(subject, claims.Userinfo.Id)
+		}
+		return []byte(key), err
+	}
+}
@@ -0,0 +1,120 @@
+package jwt
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionMax(t *testing.T) {
+	store := NewMemoryKeyStore("test")
+	var sessions []Session
+	for i := 0; i < 4; i++ {
+		session, _, err := NewSession(store, "app_1", "1", "d", time.Hour, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, session)
+	}
+	list, err := ListSessions(store, "app_1", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Id != sessions[2].Id || list[1].Id != sessions[3].Id {
+		t.Fatal("newest sessions not kept in order", list)
+	}
+	for _, session := range sessions[:2] {
+		if key, _ := store.GetSessionKey("app_1", "1", session.Id); key != "" {
+			t.Fatal("oldest session not revoked")
+		}
+	}
+
+	if _, _, err = NewSession(store, "app_1", "2", "d", time.Hour, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = NewSession(store, "app_1", "2", "d", time.Hour, 0); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ = ListSessions(store, "app_1", "2"); len(list) != 2 {
+		t.Fatal("unlimited sessions evicted", list)
+	}
+}
+
+func TestSessionMaxConcurrent(t *testing.T) {
+	store := NewMemoryKeyStore("test")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := NewSession(store, "app_1", "1", "d", time.Hour, 3); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if list, _ := ListSessions(store, "app_1", "1"); len(list) != 3 {
+		t.Fatal("sessions exceed max", len(list))
+	}
+	keys := 0
+	for k := range store.items {
+		if k != store.keys.sessionIndex("app_1", "1") {
+			keys++
+		}
+	}
+	if keys != 3 {
+		t.Fatal("evicted session keys left", keys)
+	}
+}
+
+func TestSessionRevokeAndExpire(t *testing.T) {
+	store := NewMemoryKeyStore("test")
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	s1, key, _ := NewSession(store, "app_1", "1", "d1", time.Minute, 0)
+	s2, _, _ := NewSession(store, "app_1", "1", "d2", time.Hour, 0)
+	token, err := GenerateSessionToken("app_1", s1, key, "a", Userinfo{Id: "1"}, nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ValidateToken("app_1", "a", token, SessionKeyProvider(store, "app_1")); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	list, _ := ListSessions(store, "app_1", "1")
+	if len(list) != 1 || list[0].Id != s2.Id {
+		t.Fatal("expired session listed", list)
+	}
+	if err = RevokeSession(store, "app_1", "1", s2.Id); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ = ListSessions(store, "app_1", "1"); len(list) != 0 {
+		t.Fatal("revoked session listed", list)
+	}
+}
+
+// hashTag return the part of the key hashed by a redis cluster
+func hashTag(key string) string {
+	if i := strings.Index(key, "{"); i >= 0 {
+		if j := strings.Index(key[i+1:], "}"); j > 0 {
+			return key[i+1 : i+1+j]
+		}
+	}
+	return key
+}
+
+func TestSessionKeysSlot(t *testing.T) {
+	for _, keys := range []storeKeys{"", "api"} {
+		index := keys.sessionIndex("app_1", "1")
+		for _, key := range []string{keys.session("app_1", "1", "s1"), keys.session("app_1", "1", "")} {
+			if hashTag(key) != hashTag(index) || hashTag(key) != "app_1:1" {
+				t.Fatal("session key " + key + " not in the slot of the index " + index)
+			}
+		}
+		if hashTag(keys.sessionIndex("app_1", "2")) == hashTag(index) {
+			t.Fatal("users share the hash tag")
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// DefaultPriority 未设置优先级时的默认值，值越小越先执行
+const DefaultPriority = 1000
+
+// Rule 排序规则
+type Rule func(n *node)
+
+type node struct {
+	name     string
+	priority int
+	before   []string
+	after    []string
+	seq      int
+}
+
+// Pipeline 按优先级和前后约束排序的有序名称集合
+type Pipeline struct {
+	nodes map[string]*node
+	seq   int
+}
+
+// New return a new pipeline
+func New() *Pipeline {
+	return &Pipeline{nodes: make(map[string]*node)}
+}
+
+// Priority set the priority, smaller runs first
+func Priority(p int) Rule {
+	return func(n *node) {
+		n.priority = p
+	}
+}
+
+// Before the node must run before the named nodes
+func Before(names ...string) Rule {
+	return func(n *node) {
+		n.before = append(n.before, names...)
+	}
+}
+
+// After the node must run after the named nodes
+func After(names ...string) Rule {
+	return func(n *node) {
+		n.after = append(n.after, names...)
+	}
+}
+
+// Add a named node, an existing node with the same name is replaced but keeps its insert sequence
+func (p *Pipeline) Add(name string, rules ...Rule) {
+	n := &node{name: name, priority: DefaultPriority}
+	if old, ok := p.nodes[name]; ok {
+		n.seq = old.seq
+	} else {
+		p.seq++
+		n.seq = p.seq
+	}
+	for _, r := range rules {
+		if r != nil {
+			r(n)
+		}
+	}
+	p.nodes[name] = n
+}
+
+// Remove a named node
+func (p *Pipeline) Remove(name string) {
+	delete(p.nodes, name)
+}
+
+// Has return if the named node exists
+func (p *Pipeline) Has(name string) bool {
+	_, ok := p.nodes[name]
+	return ok
+}
+
+// Len return the node count
+func (p *Pipeline) Len() int {
+	return len(p.nodes)
+}
+
+// Resolve return the node names in run order, constraints on names not added are ignored
+func (p *Pipeline) Resolve() ([]string, error) {
+	edges := make(map[string]map[string]struct{}) // from => to
+	inDegree := make(map[string]int)
+	link := func(from, to string) {
+		if _, ok := p.nodes[from]; !ok {
+			return
+		}
+		if _, ok := p.nodes[to]; !ok {
+			return
+		}
+		if _, ok := edges[from]; !ok {
+			edges[from] = make(map[string]struct{})
+		}
+		if _, ok := edges[from][to]; ok {
+			return
+		}
+		edges[from][to] = struct{}{}
+		inDegree[to]++
+	}
+	for name, n := range p.nodes {
+		for _, b := range n.before {
+			link(name, b)
+		}
+		for _, a := range n.after {
+			link(a, name)
+		}
+	}
+
+	var ready []*node
+	for name, n := range p.nodes {
+		if inDegree[name] == 0 {
+			ready = append(ready, n)
+		}
+	}
+	var names []string
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool {
+			if ready[i].priority != ready[j].priority {
+				return ready[i].priority < ready[j].priority
+			}
+			return ready[i].seq < ready[j].seq
+		})
+		n := ready[0]
+		ready = ready[1:]
+		names = append(names, n.name)
+		for to := range edges[n.name] {
+			inDegree[to]--
+			if inDegree[to] == 0 {
+				ready = append(ready, p.nodes[to])
+			}
+		}
+	}
+
+	if len(names) != len(p.nodes) {
+		var cycled []string
+		for name := range p.nodes {
+			if inDegree[name] > 0 {
+				cycled = append(cycled, name)
+			}
+		}
+		sort.Strings(cycled)
+		return nil, errors.New("pipeline cycle detected between: " + strings.Join(cycled, ","))
+	}
+
+	return names, nil
+}
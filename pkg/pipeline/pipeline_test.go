@@ -0,0 +1,34 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	p := New()
+	p.Add("common")
+	p.Add("crypt", Priority(400), After("auth"))
+	p.Add("auth", Priority(200), After("app"))
+	p.Add("app", Priority(500))
+	p.Add("first", Priority(-1), Before("missing"))
+
+	names, err := p.Resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(names, ","); got != "first,app,auth,crypt,common" {
+		t.Fatal("unexpected order: " + got)
+	}
+}
+
+func TestResolveCycle(t *testing.T) {
+	p := New()
+	p.Add("a", After("b"))
+	p.Add("b", After("a"))
+	p.Add("c")
+
+	if _, err := p.Resolve(); err == nil {
+		t.Fatal("cycle not detected")
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ReadyChecker readiness checker, return nil when ready
+type ReadyChecker func(ctx context.Context) error
+
+type namedChecker struct {
+	name    string
+	checker ReadyChecker
+}
+
+// readiness the server readiness state, built-in flags and pluggable checkers
+type readiness struct {
+	sync.RWMutex
+	flags    map[string]bool
+	names    []string
+	checkers []namedChecker
+	timeout  time.Duration
+}
+
+func newReadiness(flags ...string) *readiness {
+	r := &readiness{flags: make(map[string]bool), names: flags, timeout: 3 * time.Second}
+	for _, f := range flags {
+		r.flags[f] = false
+	}
+	return r
+}
+
+func (r *readiness) set(flag string, ok bool) {
+	r.Lock()
+	defer r.Unlock()
+	if _, exist := r.flags[flag]; !exist {
+		r.names = append(r.names, flag)
+	}
+	r.flags[flag] = ok
+}
+
+func (r *readiness) remove(flag string) {
+	r.Lock()
+	defer r.Unlock()
+	if _, exist := r.flags[flag]; !exist {
+		return
+	}
+	delete(r.flags, flag)
+	for i, n := range r.names {
+		if n == flag {
+			r.names = append(r.names[:i], r.names[i+1:]...)
+			break
+		}
+	}
+}
+
+func (r *readiness) add(name string, checker ReadyChecker) {
+	r.Lock()
+	defer r.Unlock()
+	r.checkers = append(r.checkers, namedChecker{name: name, checker: checker})
+}
+
+func (r *readiness) check(ctx context.Context) (bool, map[string]string) {
+	r.RLock()
+	defer r.RUnlock()
+	ready := true
+	checks := make(map[string]string, len(r.names)+len(r.checkers))
+	for _, n := range r.names {
+		if r.flags[n] {
+			checks[n] = "ok"
+		} else {
+			checks[n] = "pending"
+			ready = false
+		}
+	}
+	for _, c := range r.checkers {
+		cctx, cancel := context.WithTimeout(ctx, r.timeout)
+		if err := c.checker(cctx); err != nil {
+			checks[c.name] = err.Error()
+			ready = false
+		} else {
+			checks[c.name] = "ok"
+		}
+		cancel()
+	}
+	return ready, checks
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RegRetryPolicy registration retry policy with exponential backoff and jitter
+type RegRetryPolicy struct {
+	MaxAttempts int           // max attempts, <= 0 means retry until released
+	Initial     time.Duration // first retry delay
+	Max         time.Duration // max retry delay
+	Multiplier  float64       // delay multiplier of each retry
+	Jitter      float64       // random delay fraction in [0, 1]
+	Background  bool          // serve locally while registration keeps retrying in background, the final failure is passed to the failedCb of Run
+}
+
+// DefaultRegRetryPolicy return the default registration retry policy, a single attempt failing fast as before, set MaxAttempts to retry
+func DefaultRegRetryPolicy() RegRetryPolicy {
+	return RegRetryPolicy{
+		MaxAttempts: 1,
+		Initial:     500 * time.Millisecond,
+		Max:         10 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+	}
+}
+
+// Delay return the delay before the attempt, attempt start from 1
+func (p RegRetryPolicy) Delay(attempt int) time.Duration {
+	if attempt <= 1 {
+		return 0
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(p.Initial) * math.Pow(multiplier, float64(attempt-2))
+	if p.Max > 0 && d > float64(p.Max) {
+		d = float64(p.Max)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		d = d * (1 - jitter + 2*jitter*rand.Float64())
+	}
+	return time.Duration(d)
+}
+
+// RegState registration state
+type RegState int
+
+const (
+	RegPending RegState = iota
+	RegRegistering
+	RegRegistered
+	RegFailed
+	RegDisabled
+)
+
+func (s RegState) String() string {
+	switch s {
+	case RegPending:
+		return "pending"
+	case RegRegistering:
+		return "registering"
+	case RegRegistered:
+		return "registered"
+	case RegFailed:
+		return "failed"
+	case RegDisabled:
+		return "disabled"
+	default:
+		return "unknown"
+	}
+}
+
+// RegStatus registration status
+type RegStatus struct {
+	State    RegState
+	Attempts int
+	LastErr  error
+	Since    time.Time
+}
+
+type regStatus struct {
+	sync.RWMutex
+	status RegStatus
+}
+
+func (r *regStatus) set(state RegState, attempts int, err error) {
+	r.Lock()
+	defer r.Unlock()
+	if r.status.State != state {
+		r.status.Since = time.Now()
+	}
+	r.status.State = state
+	r.status.Attempts = attempts
+	r.status.LastErr = err
+}
+
+func (r *regStatus) get() RegStatus {
+	r.RLock()
+	defer r.RUnlock()
+	return r.status
+}
+
+// RegStatus return the registration status
+func (s *Server) RegStatus() RegStatus {
+	return s.regStatus.get()
+}
+
+// registerWithRetry register the server and the gateway with the retry policy until success, attempts exhausted or ctx done
+func (s *Server) registerWithRetry(ctx context.Context) (err error) {
+	for attempt := 1; s.regRetry.MaxAttempts <= 0 || attempt <= s.regRetry.MaxAttempts; attempt++ {
+		if d := s.regRetry.Delay(attempt); d > 0 {
+			s.logger.Debug("register retry in " + d.String() + ", attempt=" + strconv.Itoa(attempt))
+			select {
+			case <-ctx.Done():
+				s.regStatus.set(RegFailed, attempt-1, ctx.Err())
+				return ctx.Err()
+			case <-time.After(d):
+			}
+		}
+		s.regStatus.set(RegRegistering, attempt, err)
+		if err = s.registerOnce(ctx); err == nil {
+			s.regStatus.set(RegRegistered, attempt, nil)
+			return nil
+		}
+		s.logger.Warn("register failed, attempt=" + strconv.Itoa(attempt) + ", err=" + err.Error())
+	}
+	s.regStatus.set(RegFailed, s.regRetry.MaxAttempts, err)
+	return err
+}
+
+// regContext return the registration ctx, canceled by cancelReg when released or the init failed
+func (s *Server) regContext() context.Context {
+	s.regLock.Lock()
+	defer s.regLock.Unlock()
+	var ctx context.Context
+	ctx, s.regCancel = context.WithCancel(s.app.Context())
+	return ctx
+}
+
+func (s *Server) cancelReg() {
+	s.regLock.Lock()
+	defer s.regLock.Unlock()
+	if s.regCancel != nil {
+		s.regCancel()
+	}
+}
+
+func (s *Server) registerOnce(ctx context.Context) error {
+	s.regLock.Lock()
+	defer s.regLock.Unlock()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := s.register(true); err != nil {
+		return s.apiServerError(s.msg("register failed"), err)
+	}
+	s.logger.Debug("server registered")
+	s.readiness.set("registry", true)
+	if err := s.regGateway(); err != nil {
+		return err
+	}
+	s.logger.Debug("gateway registered")
+	return nil
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRegRetryPolicyDelay(t *testing.T) {
+	p := RegRetryPolicy{Initial: 100 * time.Millisecond, Max: 300 * time.Millisecond, Multiplier: 2}
+	for attempt, want := range map[int]time.Duration{1: 0, 2: 100 * time.Millisecond, 3: 200 * time.Millisecond, 4: 300 * time.Millisecond, 9: 300 * time.Millisecond} {
+		if d := p.Delay(attempt); d != want {
+			t.Fatal("unexpected delay", attempt, d)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.Delay(2); d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Fatal("delay out of the jitter range", d)
+		}
+	}
+}
+
+func TestRegisterWithRetry(t *testing.T) {
+	reg := &fakeRegister{fails: 2, keys: make(map[string]string)}
+	key := "gateway/demo"
+	policy := RegRetryPolicy{MaxAttempts: 3, Initial: time.Millisecond, Multiplier: 1}
+	s, _ := newRegServer(t, reg, &key, RegRetry(policy))
+	if err := s.registerWithRetry(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if st := s.RegStatus(); st.State != RegRegistered || st.Attempts != 3 || st.LastErr != nil {
+		t.Fatal("unexpected status", st)
+	}
+
+	reg = &fakeRegister{fails: 5, keys: make(map[string]string)}
+	policy.MaxAttempts = 2
+	s, _ = newRegServer(t, reg, &key, RegRetry(policy))
+	if err := s.registerWithRetry(context.Background()); err == nil {
+		t.Fatal("attempts exhausted without error")
+	}
+	if st := s.RegStatus(); st.State != RegFailed || st.Attempts != 2 || st.LastErr == nil {
+		t.Fatal("unexpected status", st)
+	}
+
+	policy = RegRetryPolicy{Initial: time.Hour}
+	s, _ = newRegServer(t, &fakeRegister{fails: 1, keys: make(map[string]string)}, &key, RegRetry(policy))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := s.registerWithRetry(ctx); err != context.Canceled {
+		t.Fatal("retry not stopped by the ctx", err)
+	}
+	if st := s.RegStatus(); st.State != RegFailed {
+		t.Fatal("unexpected status", st)
+	}
+}
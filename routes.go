@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/obnahsgnaw/api/internal/server"
+	"github.com/obnahsgnaw/api/service"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// route kinds
+const (
+	RouteGateway = "gateway"
+	RouteMux     = "mux"
+	RouteStatic  = "static"
+	RouteExt     = "ext"
+	RouteDoc     = "doc"
+	RouteProbe   = "probe"
+)
+
+// Route a route exposed by the server
+type Route struct {
+	Kind        string   `json:"kind"`
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Pattern     string   `json:"pattern,omitempty"`
+	RpcMethod   string   `json:"rpc_method,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	Middlewares []string `json:"middlewares,omitempty"`
+}
+
+type routeInfo struct {
+	kind    string
+	method  string
+	pattern string
+}
+
+// gin middlewares added before the pipeline middlewares for the gateway routes
+var builtinMiddlewares = []string{"rqid", "reqinfo", "replace", "drain", "version"}
+
+// Routes return all the routes exposed by the server, the gateway routes are listed from the service descriptors
+func (s *Server) Routes() []Route {
+	var routes []Route
+	var chain []string
+	chain = append(chain, builtinMiddlewares...)
+	if names, err := s.Middlewares(); err == nil {
+		chain = append(chain, names...)
+	}
+	if names, err := s.MuxMiddlewares(); err == nil {
+		chain = append(chain, names...)
+	}
+
+	for _, v := range s.Versions() {
+		known := make(map[string]routeInfo)
+		if v == s.version {
+			for _, r := range s.muxRouteInfos {
+				known[r.method+" "+server.NormalizePattern(r.pattern)] = r
+			}
+		}
+		for _, p := range s.rpcMethods.Patterns(s.versions[v].mux) {
+			r := Route{
+				Kind:        RouteGateway,
+				Method:      p.Method,
+				Path:        s.externalPath(v, p.Pattern),
+				Pattern:     p.Pattern,
+				Version:     v.String(),
+				Middlewares: chain,
+			}
+			if info, ok := known[p.Method+" "+server.NormalizePattern(p.Pattern)]; ok {
+				r.Kind = info.kind
+				r.Pattern = info.pattern
+				r.Path = s.externalPath(v, info.pattern)
+			} else {
+				r.RpcMethod = p.RpcMethod
+			}
+			routes = append(routes, r)
+		}
+	}
+
+	for _, r := range s.extRouteInfos {
+		routes = append(routes, Route{
+			Kind:   r.kind,
+			Method: r.method,
+			Path:   r.pattern,
+		})
+	}
+	return routes
+}
+
+// FormatRoutes format the routes as a plain text table
+func FormatRoutes(routes []Route) string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	_, _ = w.Write([]byte("KIND\tMETHOD\tPATH\tRPC METHOD\tMIDDLEWARES\n"))
+	for _, r := range routes {
+		_, _ = w.Write([]byte(strings.Join([]string{r.Kind, r.Method, r.Path, r.RpcMethod, strings.Join(r.Middlewares, ",")}, "\t") + "\n"))
+	}
+	_ = w.Flush()
+	return buf.String()
+}
+
+func (s *Server) externalPath(v Version, pattern string) string {
+	if s.withoutRoutePrefix {
+		return pattern
+	}
+	version := "/" + v.String()
+	if pattern == version || strings.HasPrefix(pattern, version+"/") {
+		return version + "/" + s.id + strings.TrimPrefix(pattern, version)
+	}
+	return pattern
+}
+
+// addExtRoutes add the ext routes and record what they registered on the engine
+func (s *Server) addExtRoutes(e *gin.Engine, routes []service.RouteProvider) {
+	exist := make(map[string]struct{})
+	for _, r := range e.Routes() {
+		exist[r.Method+" "+r.Path] = struct{}{}
+	}
+	server.AddExtRoute(e, routes)
+	for _, r := range e.Routes() {
+		if _, ok := exist[r.Method+" "+r.Path]; ok {
+			continue
+		}
+		kind := RouteExt
+		if _, ok := s.docPaths[r.Path]; ok {
+			kind = RouteDoc
+		}
+		s.extRouteInfos = append(s.extRouteInfos, routeInfo{kind: kind, method: r.Method, pattern: r.Path})
+	}
+	sort.Slice(s.extRouteInfos, func(i, j int) bool {
+		if s.extRouteInfos[i].pattern != s.extRouteInfos[j].pattern {
+			return s.extRouteInfos[i].pattern < s.extRouteInfos[j].pattern
+		}
+		return s.extRouteInfos[i].method < s.extRouteInfos[j].method
+	})
+}
+
+// routeDebugRoute list the routes in debug mode, json by default, plain text table for ?format=text or Accept: text/plain
+func (s *Server) routeDebugRoute(path string) service.RouteProvider {
+	return func(engine *gin.Engine) {
+		engine.GET(path, func(c *gin.Context) {
+			if !s.app.Debugger().Debug() {
+				c.Status(http.StatusNotFound)
+				return
+			}
+			routes := s.Routes()
+			if c.Query("format") == "text" || strings.HasPrefix(c.GetHeader("Accept"), "text/plain") {
+				c.String(http.StatusOK, FormatRoutes(routes))
+				return
+			}
+			c.JSON(http.StatusOK, routes)
+		})
+	}
+}
+
+// addProbeRoutes add the liveness(/{id}-healthz) and readiness(/{id}-readyz) probes, out of the drain and the ext middlewares
+func (s *Server) addProbeRoutes(e *gin.Engine) {
+	healthz, readyz := "/"+s.id+"-healthz", "/"+s.id+"-readyz"
+	server.AddProbeRoutes(e, healthz, readyz, s.Ready)
+	s.extRouteInfos = append(s.extRouteInfos,
+		routeInfo{kind: RouteProbe, method: http.MethodGet, pattern: healthz},
+		routeInfo{kind: RouteProbe, method: http.MethodGet, pattern: readyz},
+	)
+}
@@ -0,0 +1,155 @@
+package audit
+
+import (
+	"encoding/json"
+	"github.com/obnahsgnaw/api/service/autheduser"
+	"go.uber.org/zap"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+说明：
+1. 每个网关请求在响应写出后输出一条审计记录，包括被app、认证等中间件拒绝的请求
+2. 规则按 method 和 pattern 匹配，pattern 支持 path.Match 通配，先匹配网关pattern，未路由到网关时匹配请求路径
+3. 设置了包含规则时只审计匹配的请求，排除规则优先
+*/
+
+// Record an audit record of a request
+type Record struct {
+	Time      time.Time     `json:"time"`
+	RqId      string        `json:"rq_id"`
+	AppId     string        `json:"app_id,omitempty"`
+	UserId    uint32        `json:"user_id,omitempty"`
+	Uid       string        `json:"uid,omitempty"`
+	ClientIp  string        `json:"client_ip"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Pattern   string        `json:"pattern,omitempty"`
+	RpcMethod string        `json:"rpc_method,omitempty"`
+	Status    int           `json:"status"`
+	ErrCode   uint32        `json:"err_code,omitempty"`
+	Latency   time.Duration `json:"latency"`
+	BytesIn   int64         `json:"bytes_in"`
+	BytesOut  int64         `json:"bytes_out"`
+}
+
+// Sink the audit record output
+type Sink interface {
+	Write(r Record)
+}
+
+// SinkFunc a func sink
+type SinkFunc func(r Record)
+
+func (f SinkFunc) Write(r Record) {
+	f(r)
+}
+
+// Rule a method and pattern rule, an empty method matches all
+type Rule struct {
+	Method  string
+	Pattern string
+}
+
+func (r Rule) match(method, pattern, urlPath string) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	target := pattern
+	if target == "" {
+		target = urlPath
+	}
+	ok, _ := path.Match(r.Pattern, target)
+	return ok
+}
+
+// Manager audit manager
+type Manager struct {
+	sink           Sink
+	users          *autheduser.Manager
+	appIdHeaderKey string
+	includes       []Rule
+	excludes       []Rule
+}
+
+// New return an audit manager, a nil sink is replaced by the server logger
+func New(sink Sink, o ...Option) *Manager {
+	s := &Manager{
+		sink:           sink,
+		appIdHeaderKey: "X-App-Id",
+	}
+	s.With(o...)
+	return s
+}
+
+func (m *Manager) Sink() Sink {
+	return m.sink
+}
+
+// Users return the user manager the user is taken from, nil if not set
+func (m *Manager) Users() *autheduser.Manager {
+	return m.users
+}
+
+func (m *Manager) AppIdHeaderKey() string {
+	return m.appIdHeaderKey
+}
+
+// Audited return if the request is audited by the rules
+func (m *Manager) Audited(method, pattern, urlPath string) bool {
+	for _, r := range m.excludes {
+		if r.match(method, pattern, urlPath) {
+			return false
+		}
+	}
+	if len(m.includes) == 0 {
+		return true
+	}
+	for _, r := range m.includes {
+		if r.match(method, pattern, urlPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewZapSink return a sink logging the records with the fields by the logger
+func NewZapSink(l *zap.Logger) Sink {
+	return SinkFunc(func(r Record) {
+		l.Info("audit",
+			zap.Time("time", r.Time),
+			zap.String("rq_id", r.RqId),
+			zap.String("app_id", r.AppId),
+			zap.Uint32("user_id", r.UserId),
+			zap.String("uid", r.Uid),
+			zap.String("client_ip", r.ClientIp),
+			zap.String("method", r.Method),
+			zap.String("path", r.Path),
+			zap.String("pattern", r.Pattern),
+			zap.String("rpc_method", r.RpcMethod),
+			zap.Int("status", r.Status),
+			zap.Uint32("err_code", r.ErrCode),
+			zap.Duration("latency", r.Latency),
+			zap.Int64("bytes_in", r.BytesIn),
+			zap.Int64("bytes_out", r.BytesOut),
+		)
+	})
+}
+
+// NewWriterSink return a sink writing the records as json lines to the writer
+func NewWriterSink(w io.Writer) Sink {
+	var mu sync.Mutex
+	return SinkFunc(func(r Record) {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = w.Write(append(data, '\n'))
+	})
+}
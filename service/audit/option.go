@@ -0,0 +1,53 @@
+package audit
+
+import "github.com/obnahsgnaw/api/service/autheduser"
+
+type Option func(s *Manager)
+
+func (m *Manager) With(o ...Option) {
+	for _, oo := range o {
+		if oo != nil {
+			oo(m)
+		}
+	}
+}
+
+// WithSink set the sink, used by the server to set its logger sink
+func WithSink(sink Sink) Option {
+	return func(s *Manager) {
+		if sink != nil {
+			s.sink = sink
+		}
+	}
+}
+
+// UserManager take the user of the record from the user manager of the auth middleware
+func UserManager(users *autheduser.Manager) Option {
+	return func(s *Manager) {
+		s.users = users
+	}
+}
+
+func AppIdHeaderKey(key string) Option {
+	return func(s *Manager) {
+		s.appIdHeaderKey = key
+	}
+}
+
+// Include audit the requests of the method and the patterns only, the method is empty for all the methods
+func Include(method string, patterns ...string) Option {
+	return func(s *Manager) {
+		for _, p := range patterns {
+			s.includes = append(s.includes, Rule{Method: method, Pattern: p})
+		}
+	}
+}
+
+// Exclude not audit the requests of the method and the patterns
+func Exclude(method string, patterns ...string) Option {
+	return func(s *Manager) {
+		for _, p := range patterns {
+			s.excludes = append(s.excludes, Rule{Method: method, Pattern: p})
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package jwtuser
+
+import (
+	"errors"
+	"github.com/obnahsgnaw/api/pkg/jwt"
+	"github.com/obnahsgnaw/api/service/autheduser"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+说明：
+1. 基于 pkg/jwt 的 autheduser.UserProvider，token header 需为 Bearer token
+2. 每个用户的签名key存储在key storage中，按 subject 和 用户id 获取，key不存在时token失效，删除key即注销
+3. subject 默认为请求的 app id，issuer 需与签发时一致
+4. 设置了滑动过期时每次验证通过后延长用户key的有效期
+5. Login 按设备创建会话，每个会话有独立的key，可单独注销，超过最大会话数时注销最早的会话
+*/
+
+var (
+	ErrTokenEmpty  = errors.New("token empty")
+	ErrTokenScheme = errors.New("token scheme not bearer")
+	ErrKeyNotFound = errors.New("user jwt key not found, token revoked or expired")
+)
+
+const bearer = "bearer "
+
+// Provider jwt user provider
+type Provider struct {
+	store       jwt.KeyStore
+	issuer      string
+	subject     string
+	keyTtl      time.Duration
+	sliding     bool
+	rawToken    bool
+	backendAttr string
+	maxSessions int
+	idUser      func(rqId, appid, uid string) (autheduser.User, error)
+}
+
+// New return a jwt user provider with the key storage, the tokens should be issued by the issuer
+func New(store jwt.KeyStore, issuer string, o ...Option) *Provider {
+	s := &Provider{
+		store:       store,
+		issuer:      issuer,
+		keyTtl:      24 * time.Hour,
+		backendAttr: "backend",
+	}
+	s.With(o...)
+	return s
+}
+
+func (p *Provider) subjectOf(appid string) string {
+	if p.subject != "" {
+		return p.subject
+	}
+	return appid
+}
+
+// Token return the token of the token header value
+func (p *Provider) Token(header string) (string, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return "", ErrTokenEmpty
+	}
+	if len(header) > len(bearer) && strings.EqualFold(header[:len(bearer)], bearer) {
+		return strings.TrimSpace(header[len(bearer):]), nil
+	}
+	if p.rawToken {
+		return header, nil
+	}
+	return "", ErrTokenScheme
+}
+
+func (p *Provider) GetTokenUser(_, appid, token string) (autheduser.User, error) {
+	token, err := p.Token(token)
+	if err != nil {
+		return nil, err
+	}
+	subject := p.subjectOf(appid)
+	keyProvider := jwt.SessionKeyProvider(p.store, subject)
+	var sid string
+	info, err := jwt.ValidateToken(subject, p.issuer, token, func(claims *jwt.ZyClaims) ([]byte, error) {
+		sid = claims.Sid
+		key, err := keyProvider(claims)
+		if err != nil {
+			return nil, err
+		}
+		if len(key) == 0 {
+			return nil, ErrKeyNotFound
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.sliding {
+		if sid != "" {
+			err = p.store.ExpireSessionKey(subject, info.Id, sid, p.keyTtl)
+		} else {
+			err = p.store.ExpireUserJwtKey(subject, info.Id, p.keyTtl)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p.user(info), nil
+}
+
+// GetIdUser resolve the user by the id user resolver, an error if not set
+func (p *Provider) GetIdUser(rqId, appid, uid string) (autheduser.User, error) {
+	if p.idUser == nil {
+		return nil, errors.New("jwt user provider: id user resolver not set")
+	}
+	return p.idUser(rqId, appid, uid)
+}
+
+// Issue return a token of the user for the app, the user key is generated if not exists and kept for the key ttl
+func (p *Provider) Issue(appid string, info jwt.Userinfo, ttl time.Duration) (string, error) {
+	subject := p.subjectOf(appid)
+	key, err := p.store.GetUserJwtKey(subject, info.Id)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		key = string(jwt.GenKey())
+		if err = p.store.SetUserJwtKey(subject, info.Id, key, p.keyTtl); err != nil {
+			return "", err
+		}
+	}
+	return jwt.GenerateToken(subject, []byte(key), p.issuer, info, nil, ttl)
+}
+
+// Login create a session of the user on the device and return a token of it, the session key is kept for the key ttl
+func (p *Provider) Login(appid, device string, info jwt.Userinfo, ttl time.Duration) (string, jwt.Session, error) {
+	subject := p.subjectOf(appid)
+	session, key, err := jwt.NewSession(p.store, subject, info.Id, device, p.keyTtl, p.maxSessions)
+	if err != nil {
+		return "", jwt.Session{}, err
+	}
+	token, err := jwt.GenerateSessionToken(subject, session, key, p.issuer, info, nil, ttl)
+	if err != nil {
+		return "", jwt.Session{}, err
+	}
+	return token, session, nil
+}
+
+// Sessions return the active sessions of the user, the oldest first
+func (p *Provider) Sessions(appid, uid string) ([]jwt.Session, error) {
+	return jwt.ListSessions(p.store, p.subjectOf(appid), uid)
+}
+
+// RevokeSession revoke a session of the user, the tokens of the other sessions keep valid
+func (p *Provider) RevokeSession(appid, uid, sid string) error {
+	return jwt.RevokeSession(p.store, p.subjectOf(appid), uid, sid)
+}
+
+// Revoke delete the user key and all the sessions of the app, all the tokens of the user are invalid then
+func (p *Provider) Revoke(appid, uid string) error {
+	subject := p.subjectOf(appid)
+	if err := jwt.RevokeSessions(p.store, subject, uid); err != nil {
+		return err
+	}
+	return p.store.DelUserJwtKey(subject, uid)
+}
+
+func (p *Provider) user(info jwt.Userinfo) *User {
+	u := &User{info: info}
+	if id, err := strconv.ParseUint(info.Id, 10, 32); err == nil {
+		u.id = uint32(id)
+	}
+	if p.backendAttr != "" {
+		u.backend, _ = strconv.ParseBool(info.Attrs[p.backendAttr])
+	}
+	return u
+}
+
+// User the user of the token userinfo, Id is the numeric userinfo id, 0 if not numeric
+type User struct {
+	info    jwt.Userinfo
+	id      uint32
+	backend bool
+}
+
+func (u *User) Id() uint32 {
+	return u.id
+}
+
+func (u *User) Uid() string {
+	return u.info.Id
+}
+
+func (u *User) Name() string {
+	return u.info.Name
+}
+
+func (u *User) Backend() bool {
+	return u.backend
+}
+
+func (u *User) Attr(attr string) (string, bool) {
+	v, ok := u.info.Attrs[attr]
+	return v, ok
+}
+
+func (u *User) Attrs() map[string]string {
+	return u.info.Attrs
+}
+
+func (u *User) DefaultAttr(attr, defVal string) string {
+	if v, ok := u.info.Attrs[attr]; ok {
+		return v
+	}
+	return defVal
+}
+
+// Info return the token userinfo
+func (u *User) Info() jwt.Userinfo {
+	return u.info
+}
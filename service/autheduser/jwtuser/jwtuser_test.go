@@ -0,0 +1,81 @@
+package jwtuser
+
+import (
+	"github.com/obnahsgnaw/api/pkg/jwt"
+	"testing"
+	"time"
+)
+
+// expireStore record the keys lengthened
+type expireStore struct {
+	jwt.KeyStore
+	expired []string
+}
+
+func (s *expireStore) ExpireUserJwtKey(subject, id string, ttl time.Duration) error {
+	s.expired = append(s.expired, subject+":"+id+":"+ttl.String())
+	return s.KeyStore.ExpireUserJwtKey(subject, id, ttl)
+}
+
+func (s *expireStore) ExpireSessionKey(subject, id, sid string, ttl time.Duration) error {
+	s.expired = append(s.expired, subject+":"+id+":"+sid+":"+ttl.String())
+	return s.KeyStore.ExpireSessionKey(subject, id, sid, ttl)
+}
+
+func TestSlidingExpiry(t *testing.T) {
+	store := &expireStore{KeyStore: jwt.NewMemoryKeyStore("test")}
+	p := New(store, "iss", KeyTtl(time.Hour), SlidingExpiry())
+	token, err := p.Issue("app1", jwt.Userinfo{Id: "1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.GetTokenUser("", "app1", "Bearer "+token); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.expired) != 1 || store.expired[0] != "app1:1:1h0m0s" {
+		t.Fatal("user key not lengthened", store.expired)
+	}
+
+	token, session, err := p.Login("app1", "web", jwt.Userinfo{Id: "1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.GetTokenUser("", "app1", "Bearer "+token); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.expired) != 2 || store.expired[1] != "app1:1:"+session.Id+":1h0m0s" {
+		t.Fatal("session key not lengthened", store.expired)
+	}
+
+	store.expired = nil
+	p = New(store, "iss", KeyTtl(time.Hour))
+	if _, err = p.GetTokenUser("", "app1", "Bearer "+token); err != nil || len(store.expired) != 0 {
+		t.Fatal("key lengthened without sliding expiry", err)
+	}
+}
+
+func TestSlidingKeepsKey(t *testing.T) {
+	for _, sliding := range []bool{true, false} {
+		var o []Option
+		if sliding {
+			o = append(o, SlidingExpiry())
+		}
+		p := New(jwt.NewMemoryKeyStore("test"), "iss", append(o, KeyTtl(150*time.Millisecond))...)
+		token, err := p.Issue("app1", jwt.Userinfo{Id: "1"}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if _, err = p.GetTokenUser("", "app1", "Bearer "+token); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		_, err = p.GetTokenUser("", "app1", "Bearer "+token)
+		if sliding && err != nil {
+			t.Fatal("key of the active user expired", err)
+		}
+		if !sliding && err == nil {
+			t.Fatal("key lengthened without sliding expiry")
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package jwtuser
+
+import (
+	"github.com/obnahsgnaw/api/service/autheduser"
+	"time"
+)
+
+type Option func(s *Provider)
+
+func (p *Provider) With(o ...Option) {
+	for _, oo := range o {
+		if oo != nil {
+			oo(p)
+		}
+	}
+}
+
+// Subject validate and issue the tokens of all the apps with the subject instead of the app id
+func Subject(subject string) Option {
+	return func(s *Provider) {
+		s.subject = subject
+	}
+}
+
+// KeyTtl the ttl of the generated user keys, default 24h
+func KeyTtl(ttl time.Duration) Option {
+	return func(s *Provider) {
+		if ttl > 0 {
+			s.keyTtl = ttl
+		}
+	}
+}
+
+// SlidingExpiry lengthen the user key to the key ttl each time a token of the user validated
+func SlidingExpiry() Option {
+	return func(s *Provider) {
+		s.sliding = true
+	}
+}
+
+// RawToken accept the token header without the Bearer scheme as well
+func RawToken() Option {
+	return func(s *Provider) {
+		s.rawToken = true
+	}
+}
+
+// BackendAttr the userinfo attr of the backend flag, parsed as bool, default backend, empty to disable
+func BackendAttr(attr string) Option {
+	return func(s *Provider) {
+		s.backendAttr = attr
+	}
+}
+
+// MaxSessions the max concurrent sessions of a user created by Login, the oldest ones are revoked, default 0 for unlimited
+func MaxSessions(max int) Option {
+	return func(s *Provider) {
+		if max >= 0 {
+			s.maxSessions = max
+		}
+	}
+}
+
+// IdUser set the resolver of GetIdUser
+func IdUser(resolver func(rqId, appid, uid string) (autheduser.User, error)) Option {
+	return func(s *Provider) {
+		s.idUser = resolver
+	}
+}
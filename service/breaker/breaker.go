@@ -0,0 +1,313 @@
+package breaker
+
+import (
+	"github.com/obnahsgnaw/api/pkg/apierr"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+说明：
+1. 按网关pattern或rpc方法限制并发，并在后端连续失败时熔断
+2. 熔断后等待 OpenTimeout 进入半开，放行少量探测请求；探测失败则等待时间翻倍（不超过 MaxOpenTimeout），探测成功则恢复
+3. 请求结果在响应写出后判定，默认 5xx 或 apierr.RpcFailed 视为失败
+4. rpc方法在服务注册时由服务描述的http规则解析得到，没有rpc方法的路由使用 method+pattern，key在运行期间不变
+*/
+
+// KeyType what a breaker is keyed by
+type KeyType int
+
+const (
+	ByPattern KeyType = iota
+	ByRpc
+)
+
+// State breaker state
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Config breaker config of a key
+type Config struct {
+	MaxConcurrent    int           // max in-flight calls, 0 unlimited
+	FailureThreshold int           // consecutive failures to open, 0 never open
+	OpenTimeout      time.Duration // wait before the first half-open probe
+	MaxOpenTimeout   time.Duration // failed probes double the wait up to it
+	HalfOpenProbes   int           // concurrent probes allowed in half-open
+	SuccessThreshold int           // probe successes to close
+}
+
+// DefaultConfig unlimited concurrency, open after 5 consecutive failures, probe after 5s up to 1m
+func DefaultConfig() Config {
+	return Config{
+		FailureThreshold: 5,
+		OpenTimeout:      5 * time.Second,
+		MaxOpenTimeout:   time.Minute,
+		HalfOpenProbes:   1,
+		SuccessThreshold: 1,
+	}
+}
+
+// Status breaker status of a key
+type Status struct {
+	Key      string    `json:"key"`
+	State    string    `json:"state"`
+	InFlight int       `json:"in_flight"`
+	Failures int       `json:"failures"`
+	Opens    int       `json:"opens"`
+	OpenedAt time.Time `json:"opened_at"`
+	RetryAt  time.Time `json:"retry_at"`
+}
+
+// RejectError the call is rejected by the concurrency limit or the open breaker
+type RejectError struct {
+	Key        string
+	Open       bool
+	RetryAfter time.Duration
+}
+
+func (e *RejectError) Error() string {
+	if e.Open {
+		return "circuit[" + e.Key + "] open, retry after " + e.RetryAfter.String()
+	}
+	return "circuit[" + e.Key + "] too many concurrent calls"
+}
+
+type Ignorer func(method, pattern string) bool
+
+// FailureChecker return if a finished call is a failure
+type FailureChecker func(status int, errCode uint32, hasErrCode bool) bool
+
+type entry struct {
+	config    Config
+	state     State
+	inflight  int
+	probes    int
+	failures  int
+	successes int
+	opens     int
+	openWait  time.Duration
+	openedAt  time.Time
+	retryAt   time.Time
+}
+
+// Manager concurrency limiter and circuit breaker manager
+type Manager struct {
+	mu            sync.Mutex
+	by            KeyType
+	config        Config
+	overrides     map[string]Config
+	entries       map[string]*entry
+	ignoreChecker Ignorer
+	failure       FailureChecker
+	rpcMethod     func(httpMethod, pattern string) string
+	now           func() time.Time
+}
+
+// New return a breaker manager
+func New(o ...Option) *Manager {
+	s := &Manager{
+		config:    DefaultConfig(),
+		overrides: make(map[string]Config),
+		entries:   make(map[string]*entry),
+		now:       time.Now,
+	}
+	s.With(o...)
+	return s
+}
+
+func (m *Manager) Ignored(method, pattern string) bool {
+	if m.ignoreChecker != nil {
+		return m.ignoreChecker(method, pattern)
+	}
+	return false
+}
+
+// Failed return if the finished call is a failure
+func (m *Manager) Failed(status int, errCode uint32, hasErrCode bool) bool {
+	if m.failure != nil {
+		return m.failure(status, errCode, hasErrCode)
+	}
+	return status >= http.StatusInternalServerError || hasErrCode && errCode == apierr.RpcFailed.Code()
+}
+
+// Key return the breaker key of the request, the rpc method resolved at registration for ByRpc, the method and the pattern if none
+func (m *Manager) Key(r *http.Request, pattern string) string {
+	if m.by == ByRpc && m.rpcMethod != nil {
+		if v := m.rpcMethod(r.Method, pattern); v != "" {
+			return v
+		}
+	}
+	return r.Method + " " + pattern
+}
+
+// Acquire a call slot of the key, the release must be called with the call result
+func (m *Manager) Acquire(key string) (release func(failed bool), err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.entry(key)
+	now := m.now()
+
+	if e.state == Open {
+		if now.Before(e.retryAt) {
+			return nil, &RejectError{Key: key, Open: true, RetryAfter: e.retryAt.Sub(now)}
+		}
+		e.state = HalfOpen
+		e.probes = 0
+		e.successes = 0
+	}
+	probe := e.state == HalfOpen
+	if probe && e.probes >= atLeastOne(e.config.HalfOpenProbes) {
+		return nil, &RejectError{Key: key, Open: true, RetryAfter: e.config.OpenTimeout}
+	}
+	if e.config.MaxConcurrent > 0 && e.inflight >= e.config.MaxConcurrent {
+		return nil, &RejectError{Key: key}
+	}
+	e.inflight++
+	if probe {
+		e.probes++
+	}
+
+	var once sync.Once
+	return func(failed bool) {
+		once.Do(func() {
+			m.release(e, probe, failed)
+		})
+	}, nil
+}
+
+func (m *Manager) release(e *entry, probe, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.inflight--
+	if probe {
+		e.probes--
+	}
+	switch {
+	case probe && e.state == HalfOpen:
+		if failed {
+			m.open(e, backoff(e.openWait, e.config.MaxOpenTimeout))
+		} else if e.successes++; e.successes >= atLeastOne(e.config.SuccessThreshold) {
+			e.state = Closed
+			e.failures = 0
+			e.openWait = 0
+		}
+	case e.state == Closed:
+		if !failed {
+			e.failures = 0
+		} else if e.failures++; e.config.FailureThreshold > 0 && e.failures >= e.config.FailureThreshold {
+			m.open(e, e.config.OpenTimeout)
+		}
+	}
+}
+
+func (m *Manager) open(e *entry, wait time.Duration) {
+	if wait <= 0 {
+		wait = e.config.OpenTimeout
+	}
+	now := m.now()
+	e.state = Open
+	e.opens++
+	e.openWait = wait
+	e.openedAt = now
+	e.retryAt = now.Add(wait)
+}
+
+func (m *Manager) entry(key string) *entry {
+	e, ok := m.entries[key]
+	if !ok {
+		cnf, ok1 := m.overrides[key]
+		if !ok1 {
+			cnf = m.config
+		}
+		e = &entry{config: cnf}
+		m.entries[key] = e
+	}
+	return e
+}
+
+// State return the status of the key
+func (m *Manager) State(key string) (Status, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok {
+		return Status{}, false
+	}
+	return m.status(key, e), true
+}
+
+// States return the status of all the called keys
+func (m *Manager) States() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var states []Status
+	for k, e := range m.entries {
+		states = append(states, m.status(k, e))
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Key < states[j].Key
+	})
+	return states
+}
+
+// Reset close the breaker of the key
+func (m *Manager) Reset(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.entries[key]; ok {
+		e.state = Closed
+		e.failures = 0
+		e.openWait = 0
+	}
+}
+
+func (m *Manager) status(key string, e *entry) Status {
+	st := Status{
+		Key:      key,
+		State:    e.state.String(),
+		InFlight: e.inflight,
+		Failures: e.failures,
+		Opens:    e.opens,
+	}
+	if e.state != Closed {
+		st.OpenedAt = e.openedAt
+		st.RetryAt = e.retryAt
+	}
+	return st
+}
+
+// backoff double the wait up to the limit
+func backoff(wait, limit time.Duration) time.Duration {
+	wait *= 2
+	if limit > 0 && wait > limit {
+		return limit
+	}
+	return wait
+}
+
+func atLeastOne(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
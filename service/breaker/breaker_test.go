@@ -0,0 +1,109 @@
+package breaker
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStateTransitions(t *testing.T) {
+	now := time.Unix(1000, 0)
+	m := New(Defaults(Config{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Second,
+		MaxOpenTimeout:   3 * time.Second,
+		HalfOpenProbes:   1,
+		SuccessThreshold: 1,
+	}))
+	m.now = func() time.Time {
+		return now
+	}
+	call := func(failed bool) {
+		t.Helper()
+		release, err := m.Acquire("k")
+		if err != nil {
+			t.Fatal(err)
+		}
+		release(failed)
+	}
+	state := func(want State) {
+		t.Helper()
+		if st, _ := m.State("k"); st.State != want.String() {
+			t.Fatal("unexpected state: " + st.State + ", want " + want.String())
+		}
+	}
+
+	call(true)
+	call(false)
+	call(true)
+	state(Closed)
+	call(true)
+	state(Open)
+	if _, err := m.Acquire("k"); err == nil {
+		t.Fatal("open breaker not rejected")
+	}
+
+	// a failed probe opens again with the doubled wait
+	now = now.Add(time.Second)
+	release, err := m.Acquire("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state(HalfOpen)
+	if _, err = m.Acquire("k"); err == nil {
+		t.Fatal("probes over the limit not rejected")
+	}
+	release(true)
+	state(Open)
+	if st, _ := m.State("k"); st.RetryAt.Sub(now) != 2*time.Second {
+		t.Fatal("unexpected open wait: " + st.RetryAt.Sub(now).String())
+	}
+
+	// the wait is capped by MaxOpenTimeout
+	now = now.Add(2 * time.Second)
+	call(true)
+	if st, _ := m.State("k"); st.RetryAt.Sub(now) != 3*time.Second {
+		t.Fatal("unexpected open wait: " + st.RetryAt.Sub(now).String())
+	}
+
+	now = now.Add(3 * time.Second)
+	call(false)
+	state(Closed)
+	call(true)
+	state(Closed)
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	m := New(Defaults(Config{MaxConcurrent: 1}))
+	release, err := m.Acquire("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Acquire("k"); err == nil {
+		t.Fatal("concurrent call not rejected")
+	}
+	release(false)
+	release(false)
+	if _, err = m.Acquire("k"); err != nil {
+		t.Fatal("released slot not reused")
+	}
+}
+
+func TestKey(t *testing.T) {
+	rpc := map[string]string{"GET /v1/users/{id}": "/demo.UserService/Get"}
+	resolver := RpcMethodResolver(func(httpMethod, pattern string) string {
+		return rpc[httpMethod+" "+pattern]
+	})
+	r, _ := http.NewRequest("GET", "/v1/users/1", nil)
+
+	if key := New(resolver).Key(r, "/v1/users/{id}"); key != "GET /v1/users/{id}" {
+		t.Fatal("unexpected key: " + key)
+	}
+	m := New(KeyBy(ByRpc), resolver)
+	if key := m.Key(r, "/v1/users/{id}"); key != "/demo.UserService/Get" {
+		t.Fatal("unexpected key: " + key)
+	}
+	if key := m.Key(r, "/v1/files"); key != "GET /v1/files" {
+		t.Fatal("unexpected key: " + key)
+	}
+}
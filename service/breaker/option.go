@@ -0,0 +1,57 @@
+package breaker
+
+type Option func(s *Manager)
+
+func (m *Manager) With(o ...Option) {
+	for _, oo := range o {
+		if oo != nil {
+			oo(m)
+		}
+	}
+}
+
+// KeyBy set what the breakers are keyed by, default ByPattern
+func KeyBy(by KeyType) Option {
+	return func(s *Manager) {
+		s.by = by
+	}
+}
+
+// Defaults set the config of the keys without override, see DefaultConfig
+func Defaults(config Config) Option {
+	return func(s *Manager) {
+		s.config = config
+	}
+}
+
+// Override set the config of the key, the key is "METHOD pattern" or the rpc method
+func Override(key string, config Config) Option {
+	return func(s *Manager) {
+		s.overrides[key] = config
+	}
+}
+
+func IgnoreChecker(i Ignorer) Option {
+	return func(s *Manager) {
+		if i != nil {
+			s.ignoreChecker = i
+		}
+	}
+}
+
+func Failure(checker FailureChecker) Option {
+	return func(s *Manager) {
+		if checker != nil {
+			s.failure = checker
+		}
+	}
+}
+
+// RpcMethodResolver set the rpc method resolver of the ByRpc key, set by the server from the routes registered
+func RpcMethodResolver(fn func(httpMethod, pattern string) string) Option {
+	return func(s *Manager) {
+		if fn != nil {
+			s.rpcMethod = fn
+		}
+	}
+}
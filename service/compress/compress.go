@@ -0,0 +1,118 @@
+package compress
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+说明：
+1. 响应按 Accept-Encoding 协商 br、gzip、deflate 压缩，小于 MinSize 的响应不压缩
+2. 请求体 Content-Encoding: gzip 时先解压，再交给加解密中间件和网关，解压后超过 MaxRequestSize 时读取失败，默认 10MB
+3. 压缩在加密之后进行，即中间件先于加解密中间件执行，加密后的数据同样被压缩
+*/
+
+const (
+	Gzip    = "gzip"
+	Deflate = "deflate"
+	Brotli  = "br"
+)
+
+var (
+	DefaultEncodings    = []string{Brotli, Gzip, Deflate}
+	DefaultExcludeTypes = []string{"image/", "video/", "audio/", "application/zip", "application/gzip", "application/x-gzip"}
+)
+
+const (
+	DefaultMinSize        = 1024
+	DefaultMaxRequestSize = 10 << 20
+)
+
+// Config compression config, the empty values use the defaults
+type Config struct {
+	Encodings      []string // supported encodings in preferred order
+	Level          int      // compression level of the encodings, 0 the default level of each encoding
+	MinSize        int      // min response size to compress
+	ExcludeTypes   []string // content type prefixes not compressed
+	MaxRequestSize int64    // max decompressed request body size, 0 DefaultMaxRequestSize, negative unlimited
+}
+
+func (c *Config) Supported() []string {
+	if len(c.Encodings) == 0 {
+		return DefaultEncodings
+	}
+	return c.Encodings
+}
+
+func (c *Config) Min() int {
+	if c.MinSize <= 0 {
+		return DefaultMinSize
+	}
+	return c.MinSize
+}
+
+// MaxRequest return the max decompressed request body size, 0 unlimited
+func (c *Config) MaxRequest() int64 {
+	if c.MaxRequestSize == 0 {
+		return DefaultMaxRequestSize
+	}
+	if c.MaxRequestSize < 0 {
+		return 0
+	}
+	return c.MaxRequestSize
+}
+
+// Excluded return if the content type is not compressed
+func (c *Config) Excluded(contentType string) bool {
+	types := c.ExcludeTypes
+	if len(types) == 0 {
+		types = DefaultExcludeTypes
+	}
+	contentType = strings.ToLower(contentType)
+	for _, t := range types {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate return the encoding accepted with the highest quality, the preferred one for the same quality, empty if none
+func (c *Config) Negotiate(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, q := parseQuality(part)
+		if name != "" {
+			qualities[name] = q
+		}
+	}
+	encoding, best := "", 0.0
+	for _, e := range c.Supported() {
+		q, ok := qualities[e]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > best {
+			encoding, best = e, q
+		}
+	}
+	return encoding
+}
+
+func parseQuality(part string) (string, float64) {
+	name, params, _ := strings.Cut(part, ";")
+	name = strings.ToLower(strings.TrimSpace(name))
+	q := 1.0
+	for _, p := range strings.Split(params, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+	}
+	return name, q
+}
@@ -0,0 +1,41 @@
+package compress
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	c := &Config{}
+	cases := map[string]string{
+		"":                        "",
+		"gzip":                    Gzip,
+		"gzip, deflate, br":       Brotli,
+		"gzip;q=1, br;q=0.5":      Gzip,
+		"br;q=0, gzip;q=0.1":      Gzip,
+		"*":                       Brotli,
+		"br;q=0, *;q=0.5":         Gzip,
+		"identity":                "",
+		"GZIP ; q=0.8, deflate":   Deflate,
+		"gzip;q=0, deflate;q=0.0": "",
+	}
+	for accept, want := range cases {
+		if got := c.Negotiate(accept); got != want {
+			t.Fatal("accept " + accept + ": got " + got + ", want " + want)
+		}
+	}
+
+	c = &Config{Encodings: []string{Gzip}}
+	if got := c.Negotiate("br, gzip;q=0.1"); got != Gzip {
+		t.Fatal("unsupported encoding negotiated: " + got)
+	}
+}
+
+func TestMaxRequest(t *testing.T) {
+	if n := (&Config{}).MaxRequest(); n != DefaultMaxRequestSize {
+		t.Fatal("default max request size not applied")
+	}
+	if n := (&Config{MaxRequestSize: -1}).MaxRequest(); n != 0 {
+		t.Fatal("negative max request size not unlimited")
+	}
+	if n := (&Config{MaxRequestSize: 10}).MaxRequest(); n != 10 {
+		t.Fatal("max request size not kept")
+	}
+}
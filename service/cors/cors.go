@@ -0,0 +1,143 @@
+package cors
+
+import (
+	"github.com/obnahsgnaw/api/service/authedapp"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+说明：
+1. 允许的来源可以静态配置，也可以按app配置，即 app 的 cors_origins 属性，逗号分隔
+2. 来源支持 * 和 https://*.example.com 形式的子域名通配
+3. 预检请求不带 X-App-Id，按app配置的来源需要请求url带上 app_id 查询参数，预检时按其校验来源，不在静态列表也不在app允许的来源中的预检不返回CORS头
+4. 实际请求按 X-App-Id 校验来源，不在允许的来源中时不返回CORS头，浏览器会拒绝读取响应
+*/
+
+const (
+	// AppOriginsAttr the app attr of the allowed origins, comma separated
+	AppOriginsAttr = "cors_origins"
+	// DefaultAppIdQueryKey the query param of the app id for the preflight
+	DefaultAppIdQueryKey = "app_id"
+)
+
+var (
+	DefaultAllowMethods  = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions}
+	DefaultAllowHeaders  = []string{"Origin", "Accept", "Content-Type", "Authorization", "X-App-Id", "X-User-Id", "X-User-Iv", "X-Signature", "X-Request-Id", "X-Request-Timeout", "Grpc-Timeout", "Accept-Version", "Idempotency-Key", "If-None-Match", "If-Modified-Since", "Cache-Control"}
+	DefaultExposeHeaders = []string{"X-Request-Id", "Api-Version", "Deprecation", "Sunset", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Idempotent-Replayed", "ETag", "X-Cache"}
+)
+
+// Config cors config, the empty lists use the defaults
+type Config struct {
+	AllowOrigins     []string           // static allowed origins
+	Apps             *authedapp.Manager // allow the origins of the app attr AppOriginsAttr as well, nil disable it
+	AppIdQueryKey    string             // the query param of the app id for the preflight, DefaultAppIdQueryKey if empty
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string // exposed besides the defaults
+	AllowCredentials bool
+	MaxAge           time.Duration // preflight cache time, 0 not set
+}
+
+// MatchStatic return if the origin is allowed by the static origins, and if allowed by *
+func (c *Config) MatchStatic(origin string) (allowed, wildcard bool) {
+	return Match(origin, c.AllowOrigins)
+}
+
+// MatchApp return if the origin is allowed by the app of the request, validated by the app provider
+func (c *Config) MatchApp(r *http.Request, origin string) (allowed, wildcard bool) {
+	if c.Apps == nil {
+		return false, false
+	}
+	return c.matchApp(r, r.Header.Get(c.Apps.AppidHeaderKey()), origin)
+}
+
+// MatchPreflightApp return if the origin is allowed by the app of the app id query param, the preflight has no app id header
+func (c *Config) MatchPreflightApp(r *http.Request, origin string) (allowed, wildcard bool) {
+	if c.Apps == nil {
+		return false, false
+	}
+	key := c.AppIdQueryKey
+	if key == "" {
+		key = DefaultAppIdQueryKey
+	}
+	return c.matchApp(r, r.URL.Query().Get(key), origin)
+}
+
+func (c *Config) matchApp(r *http.Request, appId, origin string) (allowed, wildcard bool) {
+	if appId == "" {
+		return false, false
+	}
+	app, err := c.Apps.Provider().GetValidApp(r.Header.Get("X-Request-Id"), appId, c.Apps.Project, false)
+	if err != nil || app == nil {
+		return false, false
+	}
+	v, ok := app.Attr(AppOriginsAttr)
+	if !ok {
+		return false, false
+	}
+	return Match(origin, strings.Split(v, ","))
+}
+
+func (c *Config) Methods() string {
+	if len(c.AllowMethods) == 0 {
+		return strings.Join(DefaultAllowMethods, ", ")
+	}
+	return strings.Join(c.AllowMethods, ", ")
+}
+
+func (c *Config) Headers() string {
+	if len(c.AllowHeaders) == 0 {
+		return strings.Join(DefaultAllowHeaders, ", ")
+	}
+	return strings.Join(c.AllowHeaders, ", ")
+}
+
+// Exposes return the default and the configured exposed headers with the extra ones
+func (c *Config) Exposes(extra ...string) []string {
+	var headers []string
+	exist := make(map[string]struct{})
+	for _, list := range [][]string{DefaultExposeHeaders, c.ExposeHeaders, extra} {
+		for _, h := range list {
+			h = strings.TrimSpace(h)
+			k := http.CanonicalHeaderKey(h)
+			if _, ok := exist[k]; ok || h == "" {
+				continue
+			}
+			exist[k] = struct{}{}
+			headers = append(headers, h)
+		}
+	}
+	return headers
+}
+
+func (c *Config) Age() string {
+	if c.MaxAge <= 0 {
+		return ""
+	}
+	return strconv.Itoa(int(c.MaxAge / time.Second))
+}
+
+// Match return if the origin matches one of the patterns, and if matched by *
+func Match(origin string, patterns []string) (matched, wildcard bool) {
+	origin = strings.ToLower(origin)
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch {
+		case p == "":
+			continue
+		case p == "*":
+			return true, true
+		case p == origin:
+			return true, false
+		case strings.Contains(p, "://*."):
+			i := strings.Index(p, "*")
+			if strings.HasPrefix(origin, p[:i]) && strings.HasSuffix(origin, p[i+1:]) && len(origin) > len(p)-1 {
+				return true, false
+			}
+		}
+	}
+	return false, false
+}
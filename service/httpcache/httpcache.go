@@ -0,0 +1,156 @@
+package httpcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+说明：
+1. GET 网关请求的 200 响应按响应body计算 ETag，服务通过 grpc 元数据 etag 设置时使用服务的值
+2. If-None-Match 匹配或 If-Modified-Since 不早于 Last-Modified 时返回 304
+3. 服务通过 grpc 元数据 cache-control、last-modified、expires 设置对应的响应头
+4. 可选的服务端响应缓存，按 app、user、Accept、url 缓存，缓存时间取 Cache-Control 的 s-maxage、max-age，
+   否则使用默认时间；no-store、no-cache 的响应不缓存，请求带 Cache-Control: no-cache 时不读缓存
+5. 只缓存认证中间件校验过用户的请求，user 取认证后的用户而不是客户端的header；private 的响应和带 Authorization 的请求
+   默认不缓存，通过 CachePrivate 开启
+6. 服务端缓存在权限、限流中间件之后读取，被它们拒绝的请求不会得到缓存的响应
+*/
+
+// Entry a cached response
+type Entry struct {
+	Header http.Header
+	Body   []byte
+}
+
+// Cache server side response cache
+type Cache interface {
+	Get(ctx context.Context, key string) (*Entry, error)
+	Set(ctx context.Context, key string, entry Entry, ttl time.Duration) error
+}
+
+type Ignorer func(c *gin.Context) bool
+
+// Manager http caching manager
+type Manager struct {
+	cache          Cache
+	defaultTtl     time.Duration
+	appIdHeaderKey string
+	private        bool
+	weak           bool
+	ignoreChecker  Ignorer
+}
+
+// New return a http caching manager, the server side cache is disabled by default
+func New(o ...Option) *Manager {
+	s := &Manager{
+		appIdHeaderKey: "X-App-Id",
+	}
+	s.With(o...)
+	return s
+}
+
+// Cache return the server side cache, nil if disabled
+func (m *Manager) Cache() Cache {
+	return m.cache
+}
+
+func (m *Manager) Ignored(c *gin.Context) bool {
+	if m.ignoreChecker != nil {
+		return m.ignoreChecker(c)
+	}
+	return false
+}
+
+// Etag return the entity tag of the body
+func (m *Manager) Etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if m.weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// Key return the server side cache key of the request for the user authenticated by the auth middleware, false if the request
+// is not cached: no user authenticated, or with Authorization and CachePrivate not set
+func (m *Manager) Key(r *http.Request, userId string) (string, bool) {
+	if userId == "" || !m.private && r.Header.Get("Authorization") != "" {
+		return "", false
+	}
+	return r.Header.Get(m.appIdHeaderKey) + ":" + userId + ":" + r.Header.Get("Accept") + ":" + r.URL.RequestURI(), true
+}
+
+// Ttl return the server side cache time of the response, false if not cached
+func (m *Manager) Ttl(h http.Header) (time.Duration, bool) {
+	directives := ParseCacheControl(h.Get("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		return 0, false
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return 0, false
+	}
+	if _, ok := directives["private"]; ok && !m.private {
+		return 0, false
+	}
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[d]; ok {
+			if sec, err := strconv.Atoi(v); err == nil {
+				return time.Duration(sec) * time.Second, sec > 0
+			}
+		}
+	}
+	return m.defaultTtl, m.defaultTtl > 0
+}
+
+// Bypass return if the request skips the server side cache
+func (m *Manager) Bypass(r *http.Request) bool {
+	directives := ParseCacheControl(r.Header.Get("Cache-Control"))
+	_, noCache := directives["no-cache"]
+	_, noStore := directives["no-store"]
+	return noCache || noStore || strings.Contains(r.Header.Get("Pragma"), "no-cache")
+}
+
+// NotModified return if the request conditions match the response validators
+func NotModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lm.Truncate(time.Second).After(ims)
+}
+
+// ParseCacheControl parse the cache control directives, the names are lower cased
+func ParseCacheControl(v string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(v, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			directives[name] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return directives
+}
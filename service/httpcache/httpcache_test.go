@@ -0,0 +1,58 @@
+package httpcache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	m := New(ResponseCache(NewMemoryCache(0), time.Minute))
+	r, _ := http.NewRequest(http.MethodGet, "/v1/users?page=1", nil)
+	r.Header.Set("X-App-Id", "app1")
+	r.Header.Set("X-User-Id", "forged")
+	r.Header.Set("Accept", "application/json")
+
+	if _, ok := m.Key(r, ""); ok {
+		t.Fatal("request without the authenticated user cached")
+	}
+	if key, _ := m.Key(r, "u1"); key != "app1:u1:application/json:/v1/users?page=1" {
+		t.Fatal("unexpected key: " + key)
+	}
+	r.Header.Set("Authorization", "Bearer token")
+	if _, ok := m.Key(r, "u1"); ok {
+		t.Fatal("request with Authorization cached")
+	}
+	if _, ok := New(CachePrivate()).Key(r, "u1"); !ok {
+		t.Fatal("request with Authorization not cached by CachePrivate")
+	}
+}
+
+func TestTtl(t *testing.T) {
+	m := New(ResponseCache(NewMemoryCache(0), time.Minute))
+	cases := map[string]time.Duration{
+		"":                         time.Minute,
+		"max-age=10":               10 * time.Second,
+		"max-age=10, s-maxage=20":  20 * time.Second,
+		"no-store":                 0,
+		"no-cache":                 0,
+		"private, max-age=10":      0,
+		"public, max-age=0":        0,
+		`max-age="30", must-reval`: 30 * time.Second,
+	}
+	for cc, want := range cases {
+		h := http.Header{}
+		h.Set("Cache-Control", cc)
+		ttl, ok := m.Ttl(h)
+		if ok != (want > 0) || ok && ttl != want {
+			t.Fatal("cache control " + cc + ": unexpected ttl " + ttl.String())
+		}
+	}
+
+	h := http.Header{}
+	h.Set("Cache-Control", "private, max-age=10")
+	m.With(CachePrivate())
+	if ttl, ok := m.Ttl(h); !ok || ttl != 10*time.Second {
+		t.Fatal("private response not cached by CachePrivate")
+	}
+}
@@ -0,0 +1,81 @@
+package httpcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	entry   Entry
+	expires time.Time
+}
+
+// MemoryCache in-process cache, keeps maxEntries at most, the earliest expiring entries are evicted first
+type MemoryCache struct {
+	mu         sync.Mutex
+	entries    map[string]*memoryEntry
+	maxEntries int
+	lastGc     time.Time
+	now        func() time.Time
+}
+
+// NewMemoryCache return a memory cache, maxEntries <= 0 means 10000
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	return &MemoryCache{
+		entries:    make(map[string]*memoryEntry),
+		maxEntries: maxEntries,
+		lastGc:     time.Now(),
+		now:        time.Now,
+	}
+}
+
+func (c *MemoryCache) Get(_ context.Context, key string) (*Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || !c.now().Before(e.expires) {
+		return nil, nil
+	}
+	entry := e.entry
+	return &entry, nil
+}
+
+func (c *MemoryCache) Set(_ context.Context, key string, entry Entry, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.gc(now)
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[key] = &memoryEntry{entry: entry, expires: now.Add(ttl)}
+	return nil
+}
+
+// gc remove the expired entries once a minute
+func (c *MemoryCache) gc(now time.Time) {
+	if now.Sub(c.lastGc) < time.Minute {
+		return
+	}
+	c.lastGc = now
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+func (c *MemoryCache) evict() {
+	var key string
+	var expires time.Time
+	for k, e := range c.entries {
+		if key == "" || e.expires.Before(expires) {
+			key, expires = k, e.expires
+		}
+	}
+	delete(c.entries, key)
+}
@@ -0,0 +1,51 @@
+package httpcache
+
+import "time"
+
+type Option func(s *Manager)
+
+func (m *Manager) With(o ...Option) {
+	for _, oo := range o {
+		if oo != nil {
+			oo(m)
+		}
+	}
+}
+
+// ResponseCache enable the server side cache, the responses without max-age are cached for defaultTtl, 0 not cached
+func ResponseCache(cache Cache, defaultTtl time.Duration) Option {
+	return func(s *Manager) {
+		if cache != nil {
+			s.cache = cache
+			s.defaultTtl = defaultTtl
+		}
+	}
+}
+
+// WeakEtag generate weak entity tags
+func WeakEtag() Option {
+	return func(s *Manager) {
+		s.weak = true
+	}
+}
+
+func AppIdHeaderKey(key string) Option {
+	return func(s *Manager) {
+		s.appIdHeaderKey = key
+	}
+}
+
+// CachePrivate cache the private responses and the requests with Authorization as well, they are keyed by the authenticated user
+func CachePrivate() Option {
+	return func(s *Manager) {
+		s.private = true
+	}
+}
+
+func IgnoreChecker(i Ignorer) Option {
+	return func(s *Manager) {
+		if i != nil {
+			s.ignoreChecker = i
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+说明：
+1. POST/PUT/PATCH 的网关请求带 Idempotency-Key 头时，按 app id、认证中间件校验后的 user id 隔离保存第一次的响应（状态码、头、body），不读取客户端的 user id 头
+2. 相同key、相同请求的重试直接返回保存的响应，并带 Idempotent-Replayed: true 头；请求不同返回冲突错误
+3. 第一次请求处理中时重试返回冲突错误；5xx 和 429 响应不保存，key 释放后可以重试
+4. 中间件在认证、加解密之后执行，指纹基于解密后的body，保存的是加密前的响应
+*/
+
+// Response the stored response
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// Record the stored state of a key, Response is nil while the first request in progress
+type Record struct {
+	Fingerprint string    `json:"fingerprint"`
+	Response    *Response `json:"response,omitempty"`
+}
+
+// Store idempotency record store
+type Store interface {
+	// Acquire save an in-progress record of the key if absent, return the existing record otherwise
+	Acquire(ctx context.Context, key, fingerprint string, ttl time.Duration) (existing *Record, err error)
+	// Save the finished record of the key
+	Save(ctx context.Context, key string, record Record, ttl time.Duration) error
+	// Release remove the key
+	Release(ctx context.Context, key string) error
+}
+
+type Ignorer func(c *gin.Context) bool
+
+// Manager idempotency manager
+type Manager struct {
+	store          Store
+	keyHeaderKey   string
+	appIdHeaderKey string
+	ttl            time.Duration
+	lockTtl        time.Duration
+	maxBodySize    int
+	methods        map[string]struct{}
+	ignoreChecker  Ignorer
+}
+
+// New return an idempotency manager, the responses are kept for 24h, and a request is locked for 1m at most
+func New(store Store, o ...Option) *Manager {
+	s := &Manager{
+		store:          store,
+		keyHeaderKey:   "Idempotency-Key",
+		appIdHeaderKey: "X-App-Id",
+		ttl:            24 * time.Hour,
+		lockTtl:        time.Minute,
+		maxBodySize:    1 << 20,
+		methods: map[string]struct{}{
+			http.MethodPost:  {},
+			http.MethodPut:   {},
+			http.MethodPatch: {},
+		},
+	}
+	s.With(o...)
+	return s
+}
+
+func (m *Manager) Store() Store {
+	return m.store
+}
+
+func (m *Manager) KeyHeaderKey() string {
+	return m.keyHeaderKey
+}
+
+func (m *Manager) Ttl() time.Duration {
+	return m.ttl
+}
+
+func (m *Manager) LockTtl() time.Duration {
+	return m.lockTtl
+}
+
+// MaxBodySize the max response body size to store
+func (m *Manager) MaxBodySize() int {
+	return m.maxBodySize
+}
+
+// Applied return if the method is idempotency checked
+func (m *Manager) Applied(method string) bool {
+	_, ok := m.methods[method]
+	return ok
+}
+
+func (m *Manager) Ignored(c *gin.Context) bool {
+	if m.ignoreChecker != nil {
+		return m.ignoreChecker(c)
+	}
+	return false
+}
+
+// Scope return the store key of the idempotency key, scoped by the app id validated by the app middleware and the user id
+// authenticated by the auth middleware, empty if not authenticated
+func (m *Manager) Scope(r *http.Request, userId, key string) string {
+	return r.Header.Get(m.appIdHeaderKey) + ":" + userId + ":" + key
+}
+
+// Fingerprint return the fingerprint of the request method, uri and body
+func (m *Manager) Fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Storable return if the response of the status is stored, the server errors and the rate limited are retryable
+func (m *Manager) Storable(status int) bool {
+	return status < http.StatusInternalServerError && status != http.StatusTooManyRequests
+}
+
+// skipped headers are set per request or by the outer middlewares
+var skippedHeaders = []string{"Content-Length", "Content-Encoding", "Date", "Vary", "X-Request-Id"}
+
+// StoredHeader return the response headers to store, the ones set before the request handled are skipped
+func StoredHeader(before, after http.Header) http.Header {
+	h := make(http.Header)
+	for k, v := range after {
+		if _, ok := before[k]; ok {
+			continue
+		}
+		h[k] = append([]string(nil), v...)
+	}
+	for _, k := range skippedHeaders {
+		h.Del(k)
+	}
+	for k := range h {
+		if strings.HasPrefix(k, "Access-Control-") {
+			delete(h, k)
+		}
+	}
+	return h
+}
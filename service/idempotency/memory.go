@@ -0,0 +1,69 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	record  Record
+	expires time.Time
+}
+
+// MemoryStore in-process store, records are not shared between instances
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	lastGc  time.Time
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]*memoryEntry),
+		lastGc:  time.Now(),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Acquire(_ context.Context, key, fingerprint string, ttl time.Duration) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.gc(now)
+
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		rec := e.record
+		return &rec, nil
+	}
+	s.entries[key] = &memoryEntry{record: Record{Fingerprint: fingerprint}, expires: now.Add(ttl)}
+	return nil, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, key string, record Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = &memoryEntry{record: record, expires: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// gc remove the expired entries once a minute
+func (s *MemoryStore) gc(now time.Time) {
+	if now.Sub(s.lastGc) < time.Minute {
+		return
+	}
+	s.lastGc = now
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+}
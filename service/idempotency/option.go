@@ -0,0 +1,75 @@
+package idempotency
+
+import (
+	"strings"
+	"time"
+)
+
+type Option func(s *Manager)
+
+func (m *Manager) With(o ...Option) {
+	for _, oo := range o {
+		if oo != nil {
+			oo(m)
+		}
+	}
+}
+
+func KeyHeaderKey(key string) Option {
+	return func(s *Manager) {
+		s.keyHeaderKey = key
+	}
+}
+
+func AppIdHeaderKey(key string) Option {
+	return func(s *Manager) {
+		s.appIdHeaderKey = key
+	}
+}
+
+// Ttl set how long the responses are kept
+func Ttl(ttl time.Duration) Option {
+	return func(s *Manager) {
+		if ttl > 0 {
+			s.ttl = ttl
+		}
+	}
+}
+
+// LockTtl set how long a request in progress locks the key at most, it should be longer than the request timeout
+func LockTtl(ttl time.Duration) Option {
+	return func(s *Manager) {
+		if ttl > 0 {
+			s.lockTtl = ttl
+		}
+	}
+}
+
+// MaxBodySize set the max response body size to store, the larger responses release the key
+func MaxBodySize(size int) Option {
+	return func(s *Manager) {
+		if size > 0 {
+			s.maxBodySize = size
+		}
+	}
+}
+
+// Methods set the idempotency checked methods, default POST, PUT and PATCH
+func Methods(methods ...string) Option {
+	return func(s *Manager) {
+		if len(methods) > 0 {
+			s.methods = make(map[string]struct{})
+			for _, m := range methods {
+				s.methods[strings.ToUpper(m)] = struct{}{}
+			}
+		}
+	}
+}
+
+func IgnoreChecker(i Ignorer) Option {
+	return func(s *Manager) {
+		if i != nil {
+			s.ignoreChecker = i
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+// KEYS[1] record; ARGV in-progress record, ttl ms; return the existing record or false if acquired
+var acquireScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v then
+	return v
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return false
+`)
+
+// RedisStore store shared between instances by redis, the records are json encoded
+type RedisStore struct {
+	rds    *redis.Client
+	prefix string
+}
+
+// NewRedisStore return a redis store, the keys are prefixed by prefix, default "idempotency:"
+func NewRedisStore(rds *redis.Client, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "idempotency:"
+	}
+	return &RedisStore{rds: rds, prefix: prefix}
+}
+
+func (s *RedisStore) Acquire(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error) {
+	data, err := json.Marshal(Record{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+	v, err := acquireScript.Run(ctx, s.rds, []string{s.prefix + key}, string(data), ttl.Milliseconds()).Text()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec Record
+	if err = json.Unmarshal([]byte(v), &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, key string, record Record, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.rds.Set(ctx, s.prefix+key, data, ttl).Err()
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.rds.Del(ctx, s.prefix+key).Err()
+}
@@ -0,0 +1,207 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/obnahsgnaw/api/pkg/apierr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+说明：
+1. 网关请求和扩展路由请求按 kind、method、pattern、rpc方法、状态码、错误码 统计请求数和耗时
+2. 网关请求在请求信息结束回调中统计，可以拿到mux路由后的pattern、rpc方法以及gin中间件写出的错误码；未路由到mux时pattern为gin路由路径
+3. 错误码属于中间件拒绝码时同时统计中间件拒绝数，默认 app、auth、sign、crypt、perm、ratelimit、breaker、idempotency
+4. 默认使用独立的registry，多个server共用一个registry时需要设置不同的常量标签
+*/
+
+// request kinds
+const (
+	KindGateway = "gateway"
+	KindExt     = "ext"
+)
+
+// Request a finished request to observe
+type Request struct {
+	Kind      string
+	Method    string
+	Pattern   string
+	RpcMethod string
+	Status    int
+	ErrCode   uint32
+	HasErr    bool
+	Latency   time.Duration
+}
+
+// Manager metrics manager
+type Manager struct {
+	registerer  prometheus.Registerer
+	gatherer    prometheus.Gatherer
+	namespace   string
+	constLabels prometheus.Labels
+	buckets     []float64
+	rejections  map[uint32]string
+	once        sync.Once
+	err         error
+	requests    *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+	inFlight    *prometheus.GaugeVec
+	rejected    *prometheus.CounterVec
+}
+
+// New return a metrics manager with its own registry including the go and process collectors
+func New(o ...Option) *Manager {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	s := &Manager{
+		registerer: reg,
+		gatherer:   reg,
+		namespace:  "api",
+		buckets:    prometheus.DefBuckets,
+		rejections: map[uint32]string{
+			apierr.AppMidInvalid.Code():     "app",
+			apierr.AuthMidInvalid.Code():    "auth",
+			apierr.SignMidInvalid.Code():    "sign",
+			apierr.SignMidGenFailed.Code():  "sign",
+			apierr.CryptMidDecFailed.Code(): "crypt",
+			apierr.CryptMidEncFailed.Code(): "crypt",
+			apierr.PermMidNoPerm.Code():     "perm",
+			apierr.RateLimited.Code():       "ratelimit",
+			apierr.ServerBusy.Code():        "breaker",
+			apierr.CircuitOpen.Code():       "breaker",
+			apierr.IdempotencyReused.Code(): "idempotency",
+			apierr.IdempotencyBusy.Code():   "idempotency",
+		},
+	}
+	s.With(o...)
+	return s
+}
+
+// Register create and register the collectors once, the options applied after that take no effect.
+// The collectors already registered by another manager with the same options are shared
+func (m *Manager) Register() error {
+	m.once.Do(func() {
+		labels := []string{"kind", "method", "pattern", "rpc_method", "status", "err_code"}
+		m.requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   m.namespace,
+			Name:        "requests_total",
+			Help:        "Total number of the finished requests.",
+			ConstLabels: m.constLabels,
+		}, labels)
+		m.latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   m.namespace,
+			Name:        "request_duration_seconds",
+			Help:        "Latency of the finished requests.",
+			ConstLabels: m.constLabels,
+			Buckets:     m.buckets,
+		}, labels)
+		m.inFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   m.namespace,
+			Name:        "requests_in_flight",
+			Help:        "Number of the requests being served.",
+			ConstLabels: m.constLabels,
+		}, []string{"kind"})
+		m.rejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   m.namespace,
+			Name:        "middleware_rejections_total",
+			Help:        "Total number of the requests rejected by the middlewares.",
+			ConstLabels: m.constLabels,
+		}, []string{"middleware", "err_code"})
+		if c, ok := m.register(m.requests).(*prometheus.CounterVec); ok {
+			m.requests = c
+		}
+		if c, ok := m.register(m.latency).(*prometheus.HistogramVec); ok {
+			m.latency = c
+		}
+		if c, ok := m.register(m.inFlight).(*prometheus.GaugeVec); ok {
+			m.inFlight = c
+		}
+		if c, ok := m.register(m.rejected).(*prometheus.CounterVec); ok {
+			m.rejected = c
+		}
+	})
+	return m.err
+}
+
+// register the collector, return the existing one if already registered
+func (m *Manager) register(c prometheus.Collector) prometheus.Collector {
+	if err := m.registerer.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			return are.ExistingCollector
+		}
+		if m.err == nil {
+			m.err = err
+		}
+	}
+	return c
+}
+
+// Registerer return the registerer, custom collectors can be registered to
+func (m *Manager) Registerer() prometheus.Registerer {
+	return m.registerer
+}
+
+// Start count an in-flight request of the kind
+func (m *Manager) Start(kind string) {
+	_ = m.Register()
+	m.inFlight.WithLabelValues(kind).Inc()
+}
+
+// Done observe the finished request started by Start
+func (m *Manager) Done(r Request) {
+	_ = m.Register()
+	m.inFlight.WithLabelValues(r.Kind).Dec()
+	var code string
+	if r.HasErr {
+		code = strconv.FormatUint(uint64(r.ErrCode), 10)
+		if name, ok := m.rejections[r.ErrCode]; ok {
+			m.rejected.WithLabelValues(name, code).Inc()
+		}
+	}
+	values := []string{r.Kind, r.Method, r.Pattern, r.RpcMethod, strconv.Itoa(r.Status), code}
+	m.requests.WithLabelValues(values...).Inc()
+	m.latency.WithLabelValues(values...).Observe(r.Latency.Seconds())
+}
+
+// WatchRegistry export the registry status gauge, 1 for the current state returned by current and 0 for the others
+func (m *Manager) WatchRegistry(states []string, current func() string) error {
+	return m.registerer.Register(&stateCollector{
+		desc:    prometheus.NewDesc(prometheus.BuildFQName(m.namespace, "", "registry_status"), "Registry registration status, 1 for the current state.", []string{"state"}, m.constLabels),
+		states:  states,
+		current: current,
+	})
+}
+
+// Handler return the http handler exposing the metrics of the gatherer
+func (m *Manager) Handler() http.Handler {
+	_ = m.Register()
+	return promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{})
+}
+
+// stateCollector collect the state gauge at scrape time
+type stateCollector struct {
+	desc    *prometheus.Desc
+	states  []string
+	current func() string
+}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	current := c.current()
+	for _, s := range c.states {
+		var v float64
+		if s == current {
+			v = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, v, s)
+	}
+}
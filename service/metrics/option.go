@@ -0,0 +1,56 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+type Option func(s *Manager)
+
+func (m *Manager) With(o ...Option) {
+	for _, oo := range o {
+		if oo != nil {
+			oo(m)
+		}
+	}
+}
+
+// Registry register the collectors to the registerer and expose the metrics of the gatherer, such as
+// prometheus.DefaultRegisterer and prometheus.DefaultGatherer
+func Registry(registerer prometheus.Registerer, gatherer prometheus.Gatherer) Option {
+	return func(s *Manager) {
+		if registerer != nil && gatherer != nil {
+			s.registerer = registerer
+			s.gatherer = gatherer
+		}
+	}
+}
+
+// Namespace the metric name prefix, default api
+func Namespace(namespace string) Option {
+	return func(s *Manager) {
+		s.namespace = namespace
+	}
+}
+
+// ConstLabels the labels added to all the metrics, such as the server id
+func ConstLabels(labels map[string]string) Option {
+	return func(s *Manager) {
+		s.constLabels = labels
+	}
+}
+
+// Buckets the latency histogram buckets in seconds, default prometheus.DefBuckets
+func Buckets(buckets ...float64) Option {
+	return func(s *Manager) {
+		if len(buckets) > 0 {
+			s.buckets = buckets
+		}
+	}
+}
+
+// Rejection count the error codes as the rejections of the middleware
+func Rejection(middleware string, codes ...uint32) Option {
+	return func(s *Manager) {
+		for _, c := range codes {
+			s.rejections[c] = middleware
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	tokens  float64 // token bucket
+	window  int64   // sliding window index
+	prev    int64
+	curr    int64
+	last    time.Time
+	expires time.Duration
+}
+
+// MemoryLimiter in-process limiter, limits are not shared between instances
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	lastGc  time.Time
+	now     func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		entries: make(map[string]*memoryEntry),
+		lastGc:  time.Now(),
+		now:     time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.gc(now)
+
+	e, ok := l.entries[key]
+	if !ok {
+		e = &memoryEntry{tokens: float64(limit.capacity()), last: now}
+		l.entries[key] = e
+	}
+	// idle longer than expires means the bucket is full or both windows passed
+	e.expires = limit.Period * time.Duration(2+limit.capacity()/limit.Rate)
+
+	if limit.Algorithm == SlidingWindow {
+		window := now.UnixNano() / int64(limit.Period)
+		switch window - e.window {
+		case 0:
+		case 1:
+			e.prev, e.curr = e.curr, 0
+		default:
+			e.prev, e.curr = 0, 0
+		}
+		e.window = window
+		e.last = now
+		elapsed := time.Duration(now.UnixNano() - window*int64(limit.Period))
+		allowed := slidingAllowed(limit, e.prev, e.curr, elapsed)
+		if allowed {
+			e.curr++
+		}
+		return slidingWindow(limit, e.prev, e.curr, elapsed, allowed), nil
+	}
+
+	rs, tokens := tokenBucket(limit, e.tokens, now.Sub(e.last))
+	e.tokens = tokens
+	e.last = now
+	return rs, nil
+}
+
+// gc remove the idle entries once a minute
+func (l *MemoryLimiter) gc(now time.Time) {
+	if now.Sub(l.lastGc) < time.Minute {
+		return
+	}
+	l.lastGc = now
+	for k, e := range l.entries {
+		if now.Sub(e.last) > e.expires {
+			delete(l.entries, k)
+		}
+	}
+}
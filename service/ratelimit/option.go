@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"errors"
+	"time"
+)
+
+type Option func(s *Manager)
+
+func (m *Manager) With(o ...Option) {
+	for _, oo := range o {
+		if oo != nil {
+			oo(m)
+		}
+	}
+}
+
+// AddRule add a limit rule keyed by the request attributes, rules without keys limit all the requests together.
+// A zero Rate or Period disables the rule, an invalid limit is returned by Manager.Err
+func AddRule(name string, limit Limit, by ...KeyType) Option {
+	return func(s *Manager) {
+		if limit.Rate <= 0 || limit.Period <= 0 {
+			return
+		}
+		if err := limit.Validate(); err != nil {
+			if s.err == nil {
+				s.err = errors.New("rule[" + name + "] invalid, " + err.Error())
+			}
+			return
+		}
+		s.rules = append(s.rules, Rule{Name: name, By: by, Limit: limit})
+	}
+}
+
+// PerSecond return a token bucket limit of rate requests per second with the burst
+func PerSecond(rate, burst int) Limit {
+	return Limit{Algorithm: TokenBucket, Rate: rate, Period: time.Second, Burst: burst}
+}
+
+// PerWindow return a sliding window limit of rate requests per window
+func PerWindow(rate int, window time.Duration) Limit {
+	return Limit{Algorithm: SlidingWindow, Rate: rate, Period: window}
+}
+
+func AppIdHeaderKey(key string) Option {
+	return func(s *Manager) {
+		s.appIdHeaderKey = key
+	}
+}
+
+func IgnoreChecker(i Ignorer) Option {
+	return func(s *Manager) {
+		if i != nil {
+			s.ignoreChecker = i
+		}
+	}
+}
+
+// RpcMethodResolver set the rpc method resolver of the ByRpc key, set by the server from the routes registered
+func RpcMethodResolver(fn func(httpMethod, pattern string) string) Option {
+	return func(s *Manager) {
+		if fn != nil {
+			s.rpcMethod = fn
+		}
+	}
+}
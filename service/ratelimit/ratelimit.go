@@ -0,0 +1,229 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+说明：
+1. 按规则限流，规则的key由 app id、user id、客户端ip、rpc方法 组合而成，任一组成部分为空时该规则跳过
+2. user id 取认证中间件校验token后写入的值，不读取客户端的header，所以限流在mux中间件中执行，晚于所有gin中间件
+3. 客户端ip由gin引擎按其信任的代理解析，需通过 engine.SetTrustedProxies 配置，否则转发头可被客户端伪造
+4. rpc方法在服务注册时由服务描述的http规则解析得到，没有rpc方法的路由使用 method+pattern，key在运行期间不变
+*/
+
+// Algorithm limit algorithm
+type Algorithm int
+
+const (
+	TokenBucket Algorithm = iota
+	SlidingWindow
+)
+
+// KeyType the request attribute a rule is keyed by
+type KeyType int
+
+const (
+	ByApp KeyType = iota + 1
+	ByUser
+	ByIp
+	ByRpc
+)
+
+func (t KeyType) String() string {
+	switch t {
+	case ByApp:
+		return "app"
+	case ByUser:
+		return "user"
+	case ByIp:
+		return "ip"
+	case ByRpc:
+		return "rpc"
+	default:
+		return "unknown"
+	}
+}
+
+// Limit Rate requests per Period, Burst is the bucket capacity of TokenBucket, default Rate
+type Limit struct {
+	Algorithm Algorithm
+	Rate      int
+	Period    time.Duration
+	Burst     int
+}
+
+// Validate return an error if the limit can not be applied, the redis limiter works in milliseconds
+func (l Limit) Validate() error {
+	if l.Rate <= 0 {
+		return errors.New("rate should be positive")
+	}
+	if l.Period < time.Millisecond {
+		return errors.New("period should be at least 1ms")
+	}
+	return nil
+}
+
+func (l Limit) capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// Result the limit result of a request
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // wait before the next request allowed, 0 if allowed
+	ResetAfter time.Duration // wait before the limit fully reset
+}
+
+// Limiter limit backend
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Rule a named limit keyed by the request attributes
+type Rule struct {
+	Name  string
+	By    []KeyType
+	Limit Limit
+}
+
+type Ignorer func(method, pattern string) bool
+
+// Manager rate limit manager
+type Manager struct {
+	limiter        Limiter
+	rules          []Rule
+	appIdHeaderKey string
+	ignoreChecker  Ignorer
+	rpcMethod      func(httpMethod, pattern string) string
+	err            error
+}
+
+// New return a rate limit manager
+func New(limiter Limiter, o ...Option) *Manager {
+	s := &Manager{
+		limiter:        limiter,
+		appIdHeaderKey: "X-App-Id",
+	}
+	s.With(o...)
+	return s
+}
+
+func (m *Manager) Limiter() Limiter {
+	return m.limiter
+}
+
+// Err return the first error of the rules added, the invalid rules are not applied
+func (m *Manager) Err() error {
+	return m.err
+}
+
+func (m *Manager) Rules() []Rule {
+	return m.rules
+}
+
+func (m *Manager) AppIdHeaderKey() string {
+	return m.appIdHeaderKey
+}
+
+func (m *Manager) Ignored(method, pattern string) bool {
+	if m.ignoreChecker != nil {
+		return m.ignoreChecker(method, pattern)
+	}
+	return false
+}
+
+// Key return the limit key of the rule for the request, false if any attribute is empty. The user id is the one authenticated
+// by the auth middleware and the client ip is resolved by the engine, both empty if unknown
+func (m *Manager) Key(r *http.Request, pattern, userId, clientIp string, rule Rule) (string, bool) {
+	segments := []string{rule.Name}
+	for _, by := range rule.By {
+		var v string
+		switch by {
+		case ByApp:
+			v = r.Header.Get(m.appIdHeaderKey)
+		case ByUser:
+			v = userId
+		case ByIp:
+			v = clientIp
+		case ByRpc:
+			v = m.rpcKey(r.Method, pattern)
+		}
+		if v == "" {
+			return "", false
+		}
+		segments = append(segments, by.String()+"="+v)
+	}
+	return strings.Join(segments, ":"), true
+}
+
+// rpcKey return the rpc method resolved at registration, the method and the pattern if none
+func (m *Manager) rpcKey(httpMethod, pattern string) string {
+	if m.rpcMethod != nil {
+		if v := m.rpcMethod(httpMethod, pattern); v != "" {
+			return v
+		}
+	}
+	return httpMethod + " " + pattern
+}
+
+// tokenBucket take a token from the bucket with tokens left at last, return the result and the tokens left now
+func tokenBucket(limit Limit, tokens float64, elapsed time.Duration) (Result, float64) {
+	capacity := float64(limit.capacity())
+	perSec := float64(limit.Rate) / limit.Period.Seconds()
+	tokens = math.Min(capacity, tokens+elapsed.Seconds()*perSec)
+	rs := Result{Limit: limit.capacity()}
+	if tokens >= 1 {
+		tokens--
+		rs.Allowed = true
+	} else {
+		rs.RetryAfter = seconds((1 - tokens) / perSec)
+	}
+	rs.Remaining = int(tokens)
+	rs.ResetAfter = seconds((capacity - tokens) / perSec)
+	return rs, tokens
+}
+
+// slidingWindow estimate the count in the sliding window by weighting the previous window count,
+// curr includes the current request if allowed
+func slidingWindow(limit Limit, prev, curr int64, elapsed time.Duration, allowed bool) Result {
+	weight := 1 - float64(elapsed)/float64(limit.Period)
+	estimated := float64(prev)*weight + float64(curr)
+	rs := Result{Allowed: allowed, Limit: limit.Rate, ResetAfter: limit.Period - elapsed}
+	if remaining := float64(limit.Rate) - estimated; remaining > 0 {
+		rs.Remaining = int(remaining)
+	}
+	if !allowed {
+		if curr >= int64(limit.Rate) || prev == 0 {
+			rs.RetryAfter = limit.Period - elapsed
+		} else {
+			// wait until the weighted previous count drops enough for one more request
+			need := 1 - float64(int64(limit.Rate)-1-curr)/float64(prev)
+			rs.RetryAfter = time.Duration(need*float64(limit.Period)) - elapsed
+		}
+		if rs.RetryAfter <= 0 {
+			rs.RetryAfter = time.Millisecond
+		}
+	}
+	return rs
+}
+
+// slidingAllowed return if one more request is allowed in the sliding window
+func slidingAllowed(limit Limit, prev, curr int64, elapsed time.Duration) bool {
+	weight := 1 - float64(elapsed)/float64(limit.Period)
+	return float64(prev)*weight+float64(curr)+1 <= float64(limit.Rate)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func allow(t *testing.T, l *MemoryLimiter, limit Limit, at time.Duration, want bool) Result {
+	t.Helper()
+	start := time.Unix(1000, 0)
+	l.now = func() time.Time {
+		return start.Add(at)
+	}
+	rs, err := l.Allow(context.Background(), "k", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Allowed != want {
+		t.Fatal("unexpected allowed at " + at.String())
+	}
+	return rs
+}
+
+func TestTokenBucket(t *testing.T) {
+	l := NewMemoryLimiter()
+	limit := PerSecond(1, 2)
+
+	if rs := allow(t, l, limit, 0, true); rs.Remaining != 1 || rs.Limit != 2 {
+		t.Fatal("unexpected remaining after the first request")
+	}
+	allow(t, l, limit, 0, true)
+	if rs := allow(t, l, limit, 0, false); rs.RetryAfter != time.Second {
+		t.Fatal("unexpected retry after: " + rs.RetryAfter.String())
+	}
+	if rs := allow(t, l, limit, 500*time.Millisecond, false); rs.RetryAfter != 500*time.Millisecond {
+		t.Fatal("unexpected retry after: " + rs.RetryAfter.String())
+	}
+	allow(t, l, limit, time.Second, true)
+	allow(t, l, limit, time.Second, false)
+	// refilled up to the burst only
+	allow(t, l, limit, 10*time.Second, true)
+	allow(t, l, limit, 10*time.Second, true)
+	allow(t, l, limit, 10*time.Second, false)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	l := NewMemoryLimiter()
+	limit := PerWindow(2, time.Second)
+
+	allow(t, l, limit, 0, true)
+	if rs := allow(t, l, limit, 0, true); rs.Remaining != 0 {
+		t.Fatal("unexpected remaining")
+	}
+	if rs := allow(t, l, limit, 0, false); rs.RetryAfter != time.Second {
+		t.Fatal("unexpected retry after: " + rs.RetryAfter.String())
+	}
+	// half of the previous window counted
+	allow(t, l, limit, 1500*time.Millisecond, true)
+	allow(t, l, limit, 1500*time.Millisecond, false)
+	// both windows passed
+	allow(t, l, limit, 3*time.Second, true)
+	allow(t, l, limit, 3*time.Second, true)
+	allow(t, l, limit, 3*time.Second, false)
+}
+
+func TestKey(t *testing.T) {
+	rpc := map[string]string{"GET /v1/users/{id}": "/demo.UserService/Get"}
+	m := New(NewMemoryLimiter(), RpcMethodResolver(func(httpMethod, pattern string) string {
+		return rpc[httpMethod+" "+pattern]
+	}))
+	r, _ := http.NewRequest("GET", "/v1/users/1", nil)
+	r.Header.Set("X-User-Id", "forged")
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+	if _, ok := m.Key(r, "/v1/users/{id}", "", "", Rule{Name: "user", By: []KeyType{ByUser}}); ok {
+		t.Fatal("keyed by the user id header")
+	}
+	if key, _ := m.Key(r, "/v1/users/{id}", "u1", "1.2.3.4", Rule{Name: "r", By: []KeyType{ByUser, ByIp}}); key != "r:user=u1:ip=1.2.3.4" {
+		t.Fatal("unexpected key: " + key)
+	}
+	if key, _ := m.Key(r, "/v1/users/{id}", "", "", Rule{Name: "r", By: []KeyType{ByRpc}}); key != "r:rpc=/demo.UserService/Get" {
+		t.Fatal("unexpected key: " + key)
+	}
+	if key, _ := m.Key(r, "/v1/files", "", "", Rule{Name: "r", By: []KeyType{ByRpc}}); key != "r:rpc=GET /v1/files" {
+		t.Fatal("unexpected key: " + key)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	m := New(NewMemoryLimiter(), AddRule("off", PerWindow(0, time.Second)), AddRule("ok", PerWindow(1, time.Millisecond)))
+	if m.Err() != nil || len(m.Rules()) != 1 {
+		t.Fatal("valid or disabled rules not applied")
+	}
+	m.With(AddRule("sub-ms", PerWindow(1, time.Microsecond)))
+	if m.Err() == nil || len(m.Rules()) != 1 {
+		t.Fatal("sub-millisecond period accepted")
+	}
+}
@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"time"
+)
+
+// KEYS[1] bucket; ARGV capacity, tokens per ms, now ms, ttl ms; return allowed, tokens
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local v = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(v[1]) or capacity
+local ts = tonumber(v[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {allowed, tostring(tokens)}
+`)
+
+// KEYS[1] current window, KEYS[2] previous window; ARGV limit, previous window weight, ttl ms; return allowed, curr, prev
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local weight = tonumber(ARGV[2])
+local curr = tonumber(redis.call('GET', KEYS[1]) or '0')
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+if prev * weight + curr + 1 > limit then
+	return {0, curr, prev}
+end
+curr = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {1, curr, prev}
+`)
+
+// RedisLimiter limiter shared between instances by redis
+type RedisLimiter struct {
+	rds    *redis.Client
+	prefix string
+}
+
+// NewRedisLimiter return a redis limiter, the keys are prefixed by prefix, default "ratelimit:"
+func NewRedisLimiter(rds *redis.Client, prefix string) *RedisLimiter {
+	if prefix == "" {
+		prefix = "ratelimit:"
+	}
+	return &RedisLimiter{rds: rds, prefix: prefix}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+	if limit.Algorithm == SlidingWindow {
+		return l.sliding(ctx, key, limit, now)
+	}
+	return l.bucket(ctx, key, limit, now)
+}
+
+func (l *RedisLimiter) bucket(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	perMs := float64(limit.Rate) / float64(limit.Period.Milliseconds())
+	ttl := limit.Period * time.Duration(2+limit.capacity()/limit.Rate)
+	v, err := tokenBucketScript.Run(ctx, l.rds, []string{l.prefix + key},
+		limit.capacity(),
+		strconv.FormatFloat(perMs, 'f', -1, 64),
+		now.UnixMilli(),
+		ttl.Milliseconds(),
+	).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(v) != 2 {
+		return Result{}, redis.Nil
+	}
+	tokens, _ := strconv.ParseFloat(toStr(v[1]), 64)
+	// recalculate from the tokens left by the script, no elapsed time since refilled
+	rs, _ := tokenBucket(limit, tokens+boolToFloat(toInt(v[0]) == 1), 0)
+	return rs, nil
+}
+
+func (l *RedisLimiter) sliding(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	window := now.UnixNano() / int64(limit.Period)
+	elapsed := time.Duration(now.UnixNano() - window*int64(limit.Period))
+	weight := 1 - float64(elapsed)/float64(limit.Period)
+	v, err := slidingWindowScript.Run(ctx, l.rds, []string{
+		l.prefix + key + ":" + strconv.FormatInt(window, 10),
+		l.prefix + key + ":" + strconv.FormatInt(window-1, 10),
+	},
+		limit.Rate,
+		strconv.FormatFloat(weight, 'f', -1, 64),
+		(2 * limit.Period).Milliseconds(),
+	).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(v) != 3 {
+		return Result{}, redis.Nil
+	}
+	return slidingWindow(limit, toInt(v[2]), toInt(v[1]), elapsed, toInt(v[0]) == 1), nil
+}
+
+// Ping check the redis connection
+func (l *RedisLimiter) Ping(ctx context.Context) error {
+	return l.rds.Ping(ctx).Err()
+}
+
+func toInt(v interface{}) int64 {
+	switch vv := v.(type) {
+	case int64:
+		return vv
+	case string:
+		i, _ := strconv.ParseInt(vv, 10, 64)
+		return i
+	}
+	return 0
+}
+
+func toStr(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
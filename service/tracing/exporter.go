@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"io"
+	"os"
+)
+
+// Exporter the span exporter, any opentelemetry sdk exporter such as otlp can be used
+type Exporter = sdktrace.SpanExporter
+
+// NewStdoutExporter return an exporter writing the spans as json to stdout
+func NewStdoutExporter(pretty bool) (Exporter, error) {
+	return NewWriterExporter(os.Stdout, pretty)
+}
+
+// NewWriterExporter return an exporter writing the spans as json to the writer
+func NewWriterExporter(w io.Writer, pretty bool) (Exporter, error) {
+	o := []stdouttrace.Option{stdouttrace.WithWriter(w)}
+	if pretty {
+		o = append(o, stdouttrace.WithPrettyPrint())
+	}
+	return stdouttrace.New(o...)
+}
+
+// NewFileExporter return an exporter appending the spans as json lines to the file, the file is closed when shutdown
+func NewFileExporter(path string) (Exporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	e, err := NewWriterExporter(f, false)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &fileExporter{Exporter: e, f: f}, nil
+}
+
+type fileExporter struct {
+	Exporter
+	f *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	err := e.Exporter.Shutdown(ctx)
+	if err1 := e.f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+type Option func(s *Manager)
+
+func (m *Manager) With(o ...Option) {
+	for _, oo := range o {
+		if oo != nil {
+			oo(m)
+		}
+	}
+}
+
+// ServiceName the service name resource of the spans, default the server id
+func ServiceName(name string) Option {
+	return func(s *Manager) {
+		s.serviceName = name
+	}
+}
+
+// Sampler set the sampler, default parent based always sample
+func Sampler(sampler sdktrace.Sampler) Option {
+	return func(s *Manager) {
+		if sampler != nil {
+			s.sampler = sampler
+		}
+	}
+}
+
+// SampleRatio sample the root spans by the ratio, the child spans follow the parent
+func SampleRatio(ratio float64) Option {
+	return func(s *Manager) {
+		s.sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+	}
+}
+
+// SyncExport export the spans synchronously when ended instead of in batches, for debugging
+func SyncExport() Option {
+	return func(s *Manager) {
+		s.syncExport = true
+	}
+}
@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"sync"
+)
+
+/*
+说明：
+1. 请求id中间件从请求头的 traceparent、tracestate 中提取W3C链路上下文，为每个网关请求创建一个server span
+2. 网关调用rpc时链路上下文写入metadata的 traceparent、tracestate，下游rpc服务据此继续链路
+3. 内置中间件(app、认证、签名、权限、加解密)在请求span下创建子span，未启用链路追踪时为空操作
+*/
+
+// InstrumentationName the tracer name of the spans
+const InstrumentationName = "github.com/obnahsgnaw/api"
+
+// propagator W3C trace context propagator, used for both the incoming requests and the outgoing metadata
+var propagator = propagation.TraceContext{}
+
+// Manager tracing manager
+type Manager struct {
+	exporter    Exporter
+	serviceName string
+	sampler     sdktrace.Sampler
+	syncExport  bool
+	once        sync.Once
+	provider    *sdktrace.TracerProvider
+	tracer      trace.Tracer
+}
+
+// New return a tracing manager exporting the spans by the exporter, see NewStdoutExporter and NewFileExporter
+func New(exporter Exporter, o ...Option) *Manager {
+	s := &Manager{
+		exporter: exporter,
+		sampler:  sdktrace.ParentBased(sdktrace.AlwaysSample()),
+	}
+	s.With(o...)
+	return s
+}
+
+func (m *Manager) ServiceName() string {
+	return m.serviceName
+}
+
+// Provider return the tracer provider, created at the first call, the options applied after that take no effect
+func (m *Manager) Provider() *sdktrace.TracerProvider {
+	m.once.Do(func() {
+		o := []sdktrace.TracerProviderOption{
+			sdktrace.WithSampler(m.sampler),
+			sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(m.serviceName))),
+		}
+		if m.exporter != nil {
+			if m.syncExport {
+				o = append(o, sdktrace.WithSyncer(m.exporter))
+			} else {
+				o = append(o, sdktrace.WithBatcher(m.exporter))
+			}
+		}
+		m.provider = sdktrace.NewTracerProvider(o...)
+		m.tracer = m.provider.Tracer(InstrumentationName)
+	})
+	return m.provider
+}
+
+// Extract return the context carrying the remote span context of the request headers
+func (m *Manager) Extract(ctx context.Context, h http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+// StartServer start the server span of the request
+func (m *Manager) StartServer(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	m.Provider()
+	return m.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+}
+
+// Flush export the pending spans
+func (m *Manager) Flush(ctx context.Context) error {
+	return m.Provider().ForceFlush(ctx)
+}
+
+// Shutdown flush the pending spans and stop the exporter
+func (m *Manager) Shutdown(ctx context.Context) error {
+	return m.Provider().Shutdown(ctx)
+}
+
+// Start start a child span by the tracer provider of the span in the ctx, it is a noop span if tracing not enabled
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return trace.SpanFromContext(ctx).TracerProvider().Tracer(InstrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End end the span, record the error if not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject return the W3C trace context of the span in the ctx as metadata pairs, empty if no valid span
+func Inject(ctx context.Context) map[string]string {
+	md := propagation.MapCarrier{}
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		propagator.Inject(ctx, md)
+	}
+	return md
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"strings"
+	"sync"
+)
+
+// State server lifecycle state
+type State int
+
+const (
+	StateCreated State = iota
+	StateInitializing
+	StateRunning
+	StateDraining
+	StateStopped
+)
+
+func (s State) String() string {
+	switch s {
+	case StateCreated:
+		return "created"
+	case StateInitializing:
+		return "initializing"
+	case StateRunning:
+		return "running"
+	case StateDraining:
+		return "draining"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// StateError the call is not allowed in the current state
+type StateError struct {
+	Op      string
+	State   State
+	Allowed []State
+}
+
+func (e *StateError) Error() string {
+	var allowed []string
+	for _, st := range e.Allowed {
+		allowed = append(allowed, st.String())
+	}
+	return "api server: " + e.Op + " not allowed in state " + e.State.String() + ", allowed=" + strings.Join(allowed, ",")
+}
+
+type lifecycle struct {
+	sync.RWMutex
+	state State
+	hooks []func(from, to State)
+	done  chan struct{}
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{done: make(chan struct{})}
+}
+
+func (l *lifecycle) get() State {
+	l.RLock()
+	defer l.RUnlock()
+	return l.state
+}
+
+// check return a StateError if the current state not in allowed
+func (l *lifecycle) check(op string, allowed ...State) error {
+	l.RLock()
+	defer l.RUnlock()
+	return l.checkLocked(op, allowed)
+}
+
+func (l *lifecycle) checkLocked(op string, allowed []State) error {
+	for _, st := range allowed {
+		if l.state == st {
+			return nil
+		}
+	}
+	return &StateError{Op: op, State: l.state, Allowed: allowed}
+}
+
+// transit move to the state if the current state in from, the hooks are called after the transition
+func (l *lifecycle) transit(op string, to State, from ...State) (State, error) {
+	l.Lock()
+	if err := l.checkLocked(op, from); err != nil {
+		l.Unlock()
+		return l.state, err
+	}
+	old := l.state
+	l.state = to
+	if to == StateStopped {
+		close(l.done)
+	}
+	hooks := l.hooks
+	l.Unlock()
+	for _, h := range hooks {
+		h(old, to)
+	}
+	return old, nil
+}
+
+func (l *lifecycle) addHook(hook func(from, to State)) {
+	l.Lock()
+	defer l.Unlock()
+	l.hooks = append(l.hooks, hook)
+}
+
+// State return the lifecycle state
+func (s *Server) State() State {
+	return s.lifecycle.get()
+}
+
+// Done return a channel closed when the server stopped
+func (s *Server) Done() <-chan struct{} {
+	return s.lifecycle.done
+}
+
+// OnStateChange add a lifecycle state change hook, called synchronously after each transition
+func (s *Server) OnStateChange(hook func(from, to State)) {
+	if hook != nil {
+		s.lifecycle.addHook(hook)
+	}
+}
+
+// configurable return an error if the server is no longer configurable
+func (s *Server) configurable(op string) error {
+	return s.lifecycle.check(op, StateCreated)
+}
+
+func (s *Server) transit(op string, to State, from ...State) (State, error) {
+	old, err := s.lifecycle.transit(op, to, from...)
+	if err == nil {
+		s.logger.Debug("state " + old.String() + " => " + to.String())
+	}
+	return old, err
+}
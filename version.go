@@ -0,0 +1,85 @@
+package api
+
+import (
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/obnahsgnaw/api/internal/server"
+	"sort"
+	"time"
+)
+
+// VersionOption api version option
+type VersionOption func(v *apiVersion)
+
+type apiVersion struct {
+	version     Version
+	mux         *runtime.ServeMux
+	services    []ServiceProvider
+	deprecation time.Time
+	sunset      time.Time
+}
+
+// Deprecated mark the version deprecated since the time, responses get the Deprecation header
+func Deprecated(at time.Time) VersionOption {
+	return func(v *apiVersion) {
+		v.deprecation = at
+	}
+}
+
+// Sunset set the time the version will be removed, responses get the Sunset header
+func Sunset(at time.Time) VersionOption {
+	return func(v *apiVersion) {
+		v.sunset = at
+	}
+}
+
+// AddVersion mount another api version beside the default one with its own mux and service providers, or update the version options
+func (s *Server) AddVersion(v Version, options ...VersionOption) error {
+	if err := s.configurable("add version"); err != nil {
+		return err
+	}
+	av, ok := s.versions[v]
+	if !ok {
+		av = &apiVersion{version: v, mux: server.NewMux()}
+		s.versions[v] = av
+	}
+	for _, o := range options {
+		if o != nil {
+			o(av)
+		}
+	}
+	return nil
+}
+
+// RegisterVersionApiService register an api service to the version, the version is added if not exist
+func (s *Server) RegisterVersionApiService(v Version, provider ServiceProvider) error {
+	if err := s.AddVersion(v); err != nil {
+		return err
+	}
+	s.versions[v].services = append(s.versions[v].services, provider)
+	return nil
+}
+
+// Version return the default api version
+func (s *Server) Version() Version {
+	return s.version
+}
+
+// Versions return all the mounted api versions in order
+func (s *Server) Versions() []Version {
+	var vs []Version
+	for v := range s.versions {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		return vs[i] < vs[j]
+	})
+	return vs
+}
+
+// VersionMux return the mux of the version, the handlers added to it directly are not listed by Routes
+func (s *Server) VersionMux(v Version) (*runtime.ServeMux, bool) {
+	if av, ok := s.versions[v]; ok {
+		return av.mux, true
+	}
+	return nil, false
+}
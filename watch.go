@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"github.com/obnahsgnaw/application/service/regCenter"
+	"time"
+)
+
+// RegChecker check whether the registered info still exists in the registry, without a checker the registrations are re-put each watch
+type RegChecker func(ctx context.Context, info *regCenter.RegInfo) (bool, error)
+
+// WatchEventType registration watch event type
+type WatchEventType int
+
+const (
+	RegLost WatchEventType = iota + 1
+	RegRestored
+	GatewayChanged
+	WatchFailed
+)
+
+func (t WatchEventType) String() string {
+	switch t {
+	case RegLost:
+		return "reg-lost"
+	case RegRestored:
+		return "reg-restored"
+	case GatewayChanged:
+		return "gateway-changed"
+	case WatchFailed:
+		return "watch-failed"
+	default:
+		return "unknown"
+	}
+}
+
+// WatchEvent registration state transition, Info for http and doc registration, Key for gateway
+type WatchEvent struct {
+	Type WatchEventType
+	Info *regCenter.RegInfo
+	Key  string
+	Err  error
+}
+
+type watcher struct {
+	interval time.Duration
+	checker  RegChecker
+	hooks    []func(WatchEvent)
+	lost     map[*regCenter.RegInfo]bool
+	cancel   context.CancelFunc
+}
+
+func newWatcher() *watcher {
+	return &watcher{
+		interval: 10 * time.Second,
+		lost:     make(map[*regCenter.RegInfo]bool),
+	}
+}
+
+// OnWatchEvent add a registration watch event hook
+func (s *Server) OnWatchEvent(hook func(WatchEvent)) {
+	if hook != nil {
+		s.watcher.hooks = append(s.watcher.hooks, hook)
+	}
+}
+
+// watch keep the registry and gateway registrations alive in background until the registration ctx done, not started if done already
+func (s *Server) watch(regCtx context.Context) {
+	if s.app.Register() == nil || s.watcher.interval <= 0 {
+		return
+	}
+	s.regLock.Lock()
+	defer s.regLock.Unlock()
+	if s.watcher.cancel != nil || regCtx.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(regCtx)
+	s.watcher.cancel = cancel
+	go func() {
+		ticker := time.NewTicker(s.watcher.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.watchOnce(ctx)
+			}
+		}
+	}()
+	s.logger.Debug("registration watcher started")
+}
+
+func (s *Server) stopWatch() {
+	s.regLock.Lock()
+	defer s.regLock.Unlock()
+	if s.watcher.cancel != nil {
+		s.watcher.cancel()
+		s.watcher.cancel = nil
+		s.logger.Debug("registration watcher stopped")
+	}
+}
+
+func (s *Server) watchOnce(ctx context.Context) {
+	s.regLock.Lock()
+	defer s.regLock.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	var infos []*regCenter.RegInfo
+	if s.regEnable {
+		infos = append(infos, s.regInfo)
+	}
+	infos = append(infos, s.docRegInfos...)
+	for _, info := range infos {
+		if s.watcher.checker != nil {
+			s.watchReg(ctx, info)
+		} else {
+			s.keepReg(info)
+		}
+	}
+
+	oldKey := s.gatewayKey
+	if err := s.refreshGateway(); err != nil {
+		s.emitWatchEvent(WatchEvent{Type: WatchFailed, Key: oldKey, Err: err})
+	} else if s.gatewayKey != oldKey {
+		s.emitWatchEvent(WatchEvent{Type: GatewayChanged, Key: s.gatewayKey})
+	}
+}
+
+func (s *Server) watchReg(ctx context.Context, info *regCenter.RegInfo) {
+	exist, err := s.watcher.checker(ctx, info)
+	if err != nil {
+		s.emitWatchEvent(WatchEvent{Type: WatchFailed, Info: info, Err: err})
+		return
+	}
+	if exist {
+		return
+	}
+	if !s.watcher.lost[info] {
+		s.watcher.lost[info] = true
+		s.emitWatchEvent(WatchEvent{Type: RegLost, Info: info})
+	}
+	if err = s.app.DoRegister(info, func(msg string) { s.logger.Debug(msg) }); err != nil {
+		s.emitWatchEvent(WatchEvent{Type: WatchFailed, Info: info, Err: err})
+		return
+	}
+	delete(s.watcher.lost, info)
+	s.emitWatchEvent(WatchEvent{Type: RegRestored, Info: info})
+}
+
+// keepReg re-put the registration, restore it if the lease expired
+func (s *Server) keepReg(info *regCenter.RegInfo) {
+	if err := s.app.DoRegister(info, func(msg string) { s.logger.Debug(msg) }); err != nil {
+		if !s.watcher.lost[info] {
+			s.watcher.lost[info] = true
+			s.emitWatchEvent(WatchEvent{Type: RegLost, Info: info, Err: err})
+		}
+		s.emitWatchEvent(WatchEvent{Type: WatchFailed, Info: info, Err: err})
+		return
+	}
+	if s.watcher.lost[info] {
+		delete(s.watcher.lost, info)
+		s.emitWatchEvent(WatchEvent{Type: RegRestored, Info: info})
+	}
+}
+
+func (s *Server) emitWatchEvent(e WatchEvent) {
+	msg := "registration watch: " + e.Type.String()
+	if e.Info != nil {
+		msg += ", server=" + e.Info.ServerInfo.Id + "." + e.Info.ServerInfo.EndType + "." + e.Info.ServerInfo.Type + "@" + e.Info.Host
+	}
+	if e.Key != "" {
+		msg += ", key=" + e.Key
+	}
+	if e.Err != nil {
+		s.logger.Warn(msg + ", err=" + e.Err.Error())
+	} else {
+		s.logger.Info(msg)
+	}
+	for _, h := range s.watcher.hooks {
+		h(e)
+	}
+}
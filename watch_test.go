@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"github.com/obnahsgnaw/application/service/regCenter"
+	"testing"
+)
+
+func TestWatchGatewayReput(t *testing.T) {
+	reg := &fakeRegister{keys: make(map[string]string)}
+	key := "gateway/demo"
+	var events []WatchEvent
+	s, _ := newRegServer(t, reg, &key, WatchHook(func(e WatchEvent) {
+		events = append(events, e)
+	}))
+	if err := s.regGateway(); err != nil {
+		t.Fatal(err)
+	}
+
+	// lease expired
+	delete(reg.keys, key)
+	s.watchOnce(context.Background())
+	if _, ok := reg.keys[key]; !ok {
+		t.Fatal("gateway key not re-put")
+	}
+	if len(events) != 0 {
+		t.Fatal("unexpected events", events)
+	}
+
+	key = "gateway/demo2"
+	s.watchOnce(context.Background())
+	if _, ok := reg.keys["gateway/demo"]; ok || reg.keys[key] == "" {
+		t.Fatal("gateway key not changed", reg.keys)
+	}
+	if len(events) != 1 || events[0].Type != GatewayChanged || events[0].Key != key {
+		t.Fatal("gateway change not emitted", events)
+	}
+
+	reg.fails = 1
+	s.watchOnce(context.Background())
+	if len(events) != 2 || events[1].Type != WatchFailed || events[1].Err == nil {
+		t.Fatal("watch failure not emitted", events)
+	}
+	if ready, _ := s.Ready(context.Background()); ready {
+		t.Fatal("ready after the gateway re-put failed")
+	}
+}
+
+func TestWatchRegChecker(t *testing.T) {
+	key := ""
+	exists := true
+	var events []WatchEventType
+	s, _ := newRegServer(t, &fakeRegister{keys: make(map[string]string)}, &key, RegEnable(), RegWatch(1, func(ctx context.Context, info *regCenter.RegInfo) (bool, error) {
+		return exists, nil
+	}), WatchHook(func(e WatchEvent) {
+		events = append(events, e.Type)
+	}))
+	s.initRegInfo()
+
+	s.watchOnce(context.Background())
+	if len(events) != 0 {
+		t.Fatal("unexpected events", events)
+	}
+	exists = false
+	s.watchOnce(context.Background())
+	if len(events) != 2 || events[0] != RegLost || events[1] != RegRestored {
+		t.Fatal("lost registration not restored", events)
+	}
+}